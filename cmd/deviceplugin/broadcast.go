@@ -0,0 +1,90 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import "sync"
+
+// broadcaster notifies any number of subscribers about changes of the buffer
+// store. Notifications carry no data, subscribers read the current snapshot
+// of the store. Pending notifications are coalesced, so that a slow
+// subscriber never blocks the watcher and always sends the latest state.
+type broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	closed      bool
+}
+
+// newBroadcaster returns a broadcaster without subscribers.
+func newBroadcaster() *broadcaster {
+	return &broadcaster{subscribers: make(map[chan struct{}]struct{})}
+}
+
+// subscribe returns a channel receiving change notifications and a function
+// to cancel the subscription. The channel is closed when the subscription is
+// cancelled or the broadcaster is closed.
+func (b *broadcaster) subscribe() (<-chan struct{}, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+
+	if b.closed {
+		close(ch)
+
+		return ch, func() {}
+	}
+
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() { b.unsubscribe(ch) }
+}
+
+// unsubscribe removes and closes the channel of a subscriber.
+func (b *broadcaster) unsubscribe(ch chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; !ok {
+		return
+	}
+
+	delete(b.subscribers, ch)
+	close(ch)
+}
+
+// publish notifies all subscribers.
+func (b *broadcaster) publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// a notification is already pending
+		}
+	}
+}
+
+// len returns the number of subscribers.
+func (b *broadcaster) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
+
+// close closes the channels of all subscribers. Later subscriptions receive
+// a closed channel.
+func (b *broadcaster) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+
+	b.closed = true
+}
@@ -0,0 +1,190 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// fakeListAndWatchServer records the devices sent to a ListAndWatch stream.
+type fakeListAndWatchServer struct {
+	grpc.ServerStream
+
+	ctx       context.Context
+	mu        sync.Mutex
+	responses [][]*pluginapi.Device
+}
+
+func (s *fakeListAndWatchServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeListAndWatchServer) Send(resp *pluginapi.ListAndWatchResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, resp.Devices)
+
+	return nil
+}
+
+// sent returns the number of responses sent.
+func (s *fakeListAndWatchServer) sent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.responses)
+}
+
+// last returns the devices of the last response.
+func (s *fakeListAndWatchServer) last() []*pluginapi.Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.responses[len(s.responses)-1]
+}
+
+var _ = Describe("broadcaster", func() {
+	var b *broadcaster
+
+	BeforeEach(func() {
+		b = newBroadcaster()
+	})
+
+	It("notifies all subscribers", func() {
+		first, cancelFirst := b.subscribe()
+		defer cancelFirst()
+
+		second, cancelSecond := b.subscribe()
+		defer cancelSecond()
+
+		b.publish()
+
+		Eventually(first).Should(Receive())
+		Eventually(second).Should(Receive())
+	})
+
+	It("coalesces pending notifications", func() {
+		updates, cancel := b.subscribe()
+		defer cancel()
+
+		b.publish()
+		b.publish()
+
+		Eventually(updates).Should(Receive())
+		Consistently(updates).ShouldNot(Receive())
+	})
+
+	It("closes cancelled subscriptions", func() {
+		updates, cancel := b.subscribe()
+		cancel()
+		cancel()
+
+		Expect(b.len()).To(BeZero())
+		Eventually(updates).Should(BeClosed())
+
+		b.publish()
+	})
+
+	It("closes all subscriptions when closed", func() {
+		updates, cancel := b.subscribe()
+		defer cancel()
+
+		b.close()
+
+		Eventually(updates).Should(BeClosed())
+
+		late, _ := b.subscribe()
+		Eventually(late).Should(BeClosed())
+	})
+})
+
+var _ = Describe("ListAndWatch", func() {
+	var plugin *ExcatDevicePlugin
+
+	BeforeEach(func() {
+		plugin = &ExcatDevicePlugin{
+			resourceName: "l3",
+			store:        newBufferStore(nil, testBuffers(2, pluginapi.Healthy)),
+			updates:      newBroadcaster(),
+		}
+	})
+
+	// listAndWatch runs ListAndWatch for a new stream in the background.
+	listAndWatch := func() (*fakeListAndWatchServer, context.CancelFunc, chan error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		server := &fakeListAndWatchServer{ctx: ctx}
+		done := make(chan error, 1)
+
+		go func() {
+			done <- plugin.ListAndWatch(&pluginapi.Empty{}, server)
+		}()
+
+		Eventually(server.sent).Should(Equal(1))
+
+		return server, cancel, done
+	}
+
+	It("broadcasts changes to every stream", func() {
+		first, cancelFirst, doneFirst := listAndWatch()
+		second, cancelSecond, doneSecond := listAndWatch()
+
+		Expect(plugin.updates.len()).To(Equal(2))
+
+		_, _, err := plugin.store.update(func(*bufferState) (*bufferState, error) {
+			return newBufferState(nil, testBuffers(3, pluginapi.Healthy)), nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		plugin.updates.publish()
+
+		Eventually(first.sent).Should(Equal(2))
+		Eventually(second.sent).Should(Equal(2))
+		Expect(first.last()).To(HaveLen(3))
+		Expect(second.last()).To(HaveLen(3))
+
+		cancelFirst()
+		cancelSecond()
+		Eventually(doneFirst).Should(Receive(BeNil()))
+		Eventually(doneSecond).Should(Receive(BeNil()))
+	})
+
+	It("unsubscribes streams whose context is cancelled", func() {
+		_, cancel, done := listAndWatch()
+		Expect(plugin.updates.len()).To(Equal(1))
+
+		cancel()
+
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(plugin.updates.len()).To(BeZero())
+	})
+
+	It("advertises no devices when the plugin is drained", func() {
+		plugin.socket = filepath.Join(GinkgoT().TempDir(), "intel-excat-l3")
+
+		server, cancel, done := listAndWatch()
+		defer cancel()
+
+		plugin.Drain()
+
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(server.sent()).To(Equal(2))
+		Expect(server.last()).To(BeEmpty())
+	})
+
+	It("ends all streams when the plugin is stopped", func() {
+		_, cancel, done := listAndWatch()
+		defer cancel()
+
+		plugin.Stop()
+
+		Eventually(done).Should(Receive(BeNil()))
+	})
+})
@@ -6,14 +6,16 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"net"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/csl-svc/excat/pkg/rdtcat"
-	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
@@ -24,28 +26,49 @@ import (
 const (
 	loglevel           = zerolog.DebugLevel
 	timeout            = 5
-	cacheLevel2        = 2
-	cacheLevel3        = 3
-	rootResourceName   = "intel.com/"
+	retryInterval      = 30
 	rdtAnnotation      = "io.kubernetes.cri.rdt-class"
 	rdtCrirmAnnotation = "rdtclass.cri-resource-manager.intel.com/pod"
-	resctrlPath        = "/sys/fs/resctrl"
-	resourceBaseName   = "excat"
 )
 
 // Buffer keeps the rdt cat class name and the according device struct
 type Buffer struct {
-	device pluginapi.Device
-	name   string
+	device     pluginapi.Device
+	name       string
+	lockDevice string // pseudo-lock device, empty if the buffer is not pseudo-locked
+	shared     bool   // buffer shares cache with other classes
 }
 
 // ExcatDevicePlugin implements the Kubernetes device plugin API
 type ExcatDevicePlugin struct {
-	buffers      []*Buffer
+	store        *bufferStore // buffers and the snapshot they were created from
 	resourceName string
 	socket       string
 	server       *grpc.Server
-	cacheLevel   int
+	cacheLevels  []int
+	locked       bool
+	cfg          config
+	claims       *claimTracker // allocations the buffers are claimed by
+	updates      *broadcaster  // notifies ListAndWatch streams about changes
+	stopWatcher  func()        // stops the watcher and waits until it returned
+}
+
+// rootDirs keeps the root directories of the filesystems the device plugin
+// reads from
+type rootDirs struct {
+	resctrl string
+	sys     string
+	proc    string
+}
+
+// config keeps the configuration of the device plugin
+type config struct {
+	rootDirs
+	exclusiveOnly bool   // advertise only classes in exclusive mode
+	setExclusive  bool   // switch ExCAT classes to exclusive mode at startup
+	rdtConfig     string // RDT config of the container runtime to check for drift
+	inventoryAddr string // local address serving the inventory and metrics, disabled if empty
+	inventory     *inventoryPublisher
 }
 
 // patchStringValue keeps payload to patch node labels
@@ -57,103 +80,245 @@ type patchStringValue struct {
 
 // NewExcatDevicePlugin returns an initialized ExcatDevicePlugin
 func NewExcatDevicePlugin(
-	resourceName string, cacheLevel int, socket string, buffers []*Buffer,
+	res rdtcat.Resource, socket string, cfg config, rdtBuffers *rdtcat.Buffers, buffers []*Buffer,
 ) *ExcatDevicePlugin {
 	return &ExcatDevicePlugin{
-		resourceName: resourceName,
-		cacheLevel:   cacheLevel,
+		resourceName: res.Name,
+		cacheLevels:  res.CacheLevels,
+		locked:       res.Locked,
 		socket:       socket,
 		server:       nil,
-		buffers:      buffers,
+		store:        newBufferStore(rdtBuffers, buffers),
+		cfg:          cfg,
+		claims:       newClaimTracker(),
+		updates:      newBroadcaster(),
 	}
 }
 
+func parseFlags(cfg *config) {
+	flag.StringVar(&cfg.resctrl, "resctrl-root", rdtcat.RdtctrlPath, ""+
+		"root directory of the resctrl filesystem, e.g. a snapshot of /sys/fs/resctrl")
+	flag.StringVar(&cfg.sys, "sys-root", rdtcat.SysfsPath, ""+
+		"root directory of sysfs used to read the CPU cache hierarchy")
+	flag.StringVar(&cfg.proc, "proc-root", rdtcat.ProcfsPath, ""+
+		"root directory of procfs used to map tasks to pods and containers")
+	flag.BoolVar(&cfg.exclusiveOnly, "exclusive-only", false, ""+
+		"advertise only classes whose mode is exclusive")
+	flag.BoolVar(&cfg.setExclusive, "set-exclusive", false, ""+
+		"switch all shareable classes to exclusive mode at startup, so that the kernel enforces exclusivity. "+
+		"Requires resctrl to be mounted writable")
+	flag.StringVar(&cfg.rdtConfig, "rdt-config", rdtcat.RdtConfigPath, ""+
+		"RDT config file of containerd or cri-o to compare with resctrl, skipped if the file does not exist")
+
+	cfg.inventory = &inventoryPublisher{}
+	flag.StringVar(&cfg.inventory.file, "inventory-file", rdtcat.InventoryPath, ""+
+		"file to write the inventory of the node to in JSON format, disabled if empty")
+	flag.StringVar(&cfg.inventoryAddr, "inventory-address", defaultInventoryAddress, ""+
+		"local address serving the inventory at "+inventoryURLPath+" and metrics at "+metricsURLPath+", disabled if empty. "+
+		"The endpoint is not authenticated, serve it on other interfaces only if it may be reachable from the cluster")
+
+	flag.Parse()
+}
+
 func main() {
+	var cfg config
+
+	parseFlags(&cfg)
+
 	initLogger()
 
-	done := make(chan struct{})
+	if cfg.inventoryAddr != "" {
+		if err := cfg.inventory.serve(cfg.inventoryAddr); err != nil {
+			log.Error().Err(err).Msg("error when serving the inventory")
+		}
+	}
+
+	if cfg.setExclusive {
+		if err := setExclusiveModes(cfg.rootDirs); err != nil {
+			log.Error().Err(err).Msg("error when switching classes to exclusive mode")
+		}
+	}
 
 	// get initial list of devices
 	log.Debug().Msg("Get initial buffer list")
 
-	// read all buffers from /sys/fs/resctrl
-	resctrl := rdtcat.Resctrl{}
-	resctrl.ExcatBuffers = &resctrl
-	allRdtBuffers := rdtcat.Buffers{
-		Resctrl: resctrl,
+	// read all buffers from the resctrl root, wait until RDT is available.
+	// Classes configured later are picked up by the supervisor.
+	allRdtBuffers, err := cfg.readRdtBuffers()
+	for errors.Is(err, rdtcat.ErrRDTUnsupported) {
+		log.Warn().Err(err).Msgf("RDT not available, retrying in %v seconds.", retryInterval)
+		time.Sleep(retryInterval * time.Second)
+
+		allRdtBuffers, err = cfg.readRdtBuffers()
+	}
+
+	switch {
+	case errors.Is(err, rdtcat.ErrNoClasses):
+		log.Warn().Err(err).Msg("No ExCAT buffers available yet.")
+	case err != nil:
+		log.Fatal().Err(err).Msgf("error when reading buffers from %v", cfg.resctrl)
+	default:
+		logTaskOwners(allRdtBuffers)
+	}
+
+	rmAllLabels()
+
+	// start device plugins for all resources with buffers and keep them in
+	// sync with resctrl
+	if err := newSupervisor(cfg).run(context.Background()); err != nil {
+		log.Fatal().Err(err).Msgf("error when watching %v", cfg.resctrl)
 	}
+}
+
+// newRdtBuffers returns rdtcat buffers reading from the root directories.
+func (d rootDirs) newRdtBuffers() *rdtcat.Buffers {
+	rdtBuffers := rdtcat.NewBuffers(d.resctrl)
+	rdtBuffers.SysRoot = d.sys
+	rdtBuffers.ProcRoot = d.proc
+
+	return rdtBuffers
+}
+
+// readRdtBuffers reads all buffers from the root directories, creates the
+// node labels and publishes the inventory. Classes not in exclusive mode are
+// skipped if configured, pseudo-locked classes are exclusive by definition.
+func (c config) readRdtBuffers() (*rdtcat.Buffers, error) {
+	allRdtBuffers := c.newRdtBuffers()
 
 	if err := allRdtBuffers.GetAllBuffers(); err != nil {
-		log.Fatal().Err(err).Msgf("error when reading buffers from %v", resctrlPath)
+		return nil, fmt.Errorf("error when reading buffers from %v: %w", c.resctrl, err)
+	}
+
+	c.checkDrift(allRdtBuffers)
+
+	if c.exclusiveOnly {
+		allRdtBuffers.KeepMode(rdtcat.ModeExclusive, rdtcat.ModePseudoLocked)
 	}
 
 	if err := allRdtBuffers.CreateLabels(); err != nil {
-		log.Fatal().Err(err).Msg("error when creating node labels")
+		return nil, fmt.Errorf("error when creating node labels: %w", err)
 	}
 
-	rmAllLabels()
+	if c.inventory != nil {
+		c.inventory.publish(allRdtBuffers)
+	}
 
-	var dpl2, dpl3 *ExcatDevicePlugin
+	return allRdtBuffers, nil
+}
 
-	cacheLevel := [2]int{2, 3}
-	plugins := [2]*ExcatDevicePlugin{dpl2, dpl3}
+// checkDrift compares the buffers with the RDT config of the container
+// runtime and logs all drifts. A missing config file is not an error, since
+// the runtime might not use RDT at all.
+func (c config) checkDrift(rdtBuffers *rdtcat.Buffers) {
+	if c.rdtConfig == "" {
+		return
+	}
 
-	// extract buffers referring to cache level and create device plugin for each supported cache level
-	for i := 0; i < 2; i++ {
-		rdtBuffers := allRdtBuffers.ExtractBuffers(cacheLevel[i])
+	rdtConfig, err := rdtcat.LoadRdtConfig(c.rdtConfig)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Debug().Msgf("no RDT config in %v, skipping drift check", c.rdtConfig)
 
-		if rdtBuffers.ResctrlGroups != nil {
-			var countBuffers int
+		return
+	} else if err != nil {
+		log.Warn().Err(err).Msgf("cannot load RDT config from %v", c.rdtConfig)
 
-			// add node label for respective cache level
-			var label string
+		return
+	}
 
-			switch cacheLevel[i] {
-			case cacheLevel2:
-				label = allRdtBuffers.DpL2Label
-			case cacheLevel3:
-				label = allRdtBuffers.DpL3Label
-			}
+	report, err := rdtBuffers.CheckDrift(rdtConfig)
+	if err != nil {
+		log.Warn().Err(err).Msgf("cannot check drift against %v", c.rdtConfig)
 
-			if err := addNodeLabel(cacheLevel[i], label); err != nil {
-				log.Fatal().Err(err).Msg("error when patching node labels")
-			}
+		return
+	}
 
-			// create all buffers as used by the device plugin
-			resourceName := fmt.Sprintf("%s-l%v", resourceBaseName, cacheLevel[i])
-			socketName := fmt.Sprintf("%sintel-excat-l%v", pluginapi.DevicePluginPath, cacheLevel[i])
+	for _, drift := range report.Drifts {
+		log.Warn().Msgf("Drift from %v: %v", c.rdtConfig, drift)
+	}
+}
 
-			var buffers []*Buffer
+// setExclusiveModes switches all shareable classes except the default class to
+// exclusive mode. Classes the kernel refuses to switch, e.g. since they share
+// cache with another class, are logged and stay shareable.
+func setExclusiveModes(roots rootDirs) error {
+	rdtBuffers := roots.newRdtBuffers()
+
+	// without classes there is nothing to switch
+	if err := rdtBuffers.GetAllBuffers(); errors.Is(err, rdtcat.ErrNoClasses) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error when reading buffers from %v: %w", roots.resctrl, err)
+	}
 
-			for _, buf := range rdtBuffers.ResctrlGroups {
-				if buf.Name != rdtcat.DefaultClass {
-					dev := pluginapi.Device{
-						ID:     rootResourceName + resourceName + "-" + buf.Name,
-						Health: pluginapi.Healthy,
-					}
+	for _, group := range rdtBuffers.ResctrlGroups {
+		if group.Name == rdtcat.DefaultClass || group.Mode != rdtcat.ModeShareable {
+			continue
+		}
 
-					buffers = append(buffers, &Buffer{
-						device: dev,
-						name:   buf.Name,
-					})
+		if err := rdtBuffers.SetMode(group.Name, rdtcat.ModeExclusive); err != nil {
+			log.Error().Err(err).Msgf("Class %v stays %v.", group.Name, group.Mode)
 
-					countBuffers++
-				}
-			}
+			continue
+		}
 
-			// create device plugin
-			plugins[i] = NewExcatDevicePlugin(resourceName, cacheLevel[i], socketName, buffers)
-			if err := plugins[i].Start(); err != nil {
-				log.Fatal().Err(err).Msgf("error when creating device plugin for ExCAT with cache level %v", cacheLevel[i])
-			}
+		log.Info().Msgf("Switched class %v to %v mode.", group.Name, rdtcat.ModeExclusive)
+	}
+
+	return nil
+}
+
+// createBuffers creates the buffers as used by the device plugin for all
+// classes except the default class. Buffers sharing cache with other classes
+// are marked as unhealthy.
+func createBuffers(resourceName string, rdtBuffers *rdtcat.Buffers, report rdtcat.ExclusivityReport) []*Buffer {
+	var buffers []*Buffer
+
+	for _, buf := range rdtBuffers.ResctrlGroups {
+		if buf.Name == rdtcat.DefaultClass {
+			continue
+		}
+
+		health := pluginapi.Healthy
+		if report.IsShared(buf.Name) {
+			health = pluginapi.Unhealthy
+		}
+
+		dev := pluginapi.Device{
+			ID:     rdtcat.ResourcePrefix + resourceName + "-" + buf.Name,
+			Health: health,
+		}
+
+		if len(buf.MbaIDs) > 0 {
+			log.Info().Msgf("Buffer %v is allocated a memory bandwidth of %v.", dev.ID, buf.MinBandwidth())
+		}
+
+		buffer := &Buffer{
+			device: dev,
+			name:   buf.Name,
+			shared: report.IsShared(buf.Name),
+		}
 
-			log.Info().Msgf("successfully started device plugin for %v cache level %v buffers", countBuffers, cacheLevel[i])
-		} else {
-			log.Info().Msgf("no cache level %v buffers configured", cacheLevel[i])
+		if region, ok := buf.LockedRegion(); ok {
+			buffer.lockDevice = region.Device
+			log.Info().Msgf("Buffer %v is pseudo-locked on %v cache ID %v.", dev.ID, region.CacheLevel, region.CacheID)
 		}
+
+		buffers = append(buffers, buffer)
+	}
+
+	return buffers
+}
+
+// checkExclusivity checks that the buffers don't share any cache and logs
+// each detected overlap.
+func checkExclusivity(rdtBuffers *rdtcat.Buffers) rdtcat.ExclusivityReport {
+	report := rdtBuffers.CheckExclusivity()
+
+	for _, overlap := range report.Overlaps {
+		log.Error().Msgf("Buffer not exclusive: %v", overlap)
 	}
 
-	<-done
+	return report
 }
 
 // initLogger initializes the logger for the device plugin
@@ -184,8 +349,13 @@ func (b *ExcatDevicePlugin) Start() error {
 		return fmt.Errorf("could not start the gRPC server: %w", err)
 	}
 
+	// Re-register whenever kubelet restarts
+	b.startWatcher()
+
 	// Register device plugin with specified resource
 	if err := b.RegisterDevicePluginResource(); err != nil {
+		b.Stop()
+
 		return fmt.Errorf("could not register device plugin for resource %v with Kubelet: %w",
 			b.resourceName, err)
 	}
@@ -193,6 +363,64 @@ func (b *ExcatDevicePlugin) Start() error {
 	return nil
 }
 
+// Stop stops the watcher and the gRPC server and ends all ListAndWatch
+// streams.
+func (b *ExcatDevicePlugin) Stop() {
+	log.Debug().Msgf("Stop ExcatDevicePlugin %v.", b.resourceName)
+
+	b.stopWatching()
+	b.updates.close()
+
+	if b.server != nil {
+		b.server.Stop()
+	}
+}
+
+// Drain advertises an empty list of devices to all ListAndWatch streams and
+// stops the device plugin once all streams ended, at the latest after the
+// timeout.
+func (b *ExcatDevicePlugin) Drain() {
+	log.Info().Msgf("Drain device plugin for %v.", b.resourceName)
+
+	b.stopWatching()
+
+	// pending notifications are received before the closed channel, so each
+	// stream sends the empty list before it ends
+	b.store.update(func(*bufferState) (*bufferState, error) {
+		return newBufferState(nil, nil), nil
+	})
+	b.updates.publish()
+	b.updates.close()
+
+	if b.server != nil {
+		stopped := make(chan struct{})
+
+		go func() {
+			b.server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-time.After(timeout * time.Second):
+			log.Warn().Msgf("ListAndWatch streams of %v didn't end in time.", b.resourceName)
+			b.server.Stop()
+		}
+	}
+
+	if err := os.Remove(b.socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Msgf("cannot remove socket %v", b.socket)
+	}
+}
+
+// stopWatching stops the watcher if it is running.
+func (b *ExcatDevicePlugin) stopWatching() {
+	if b.stopWatcher != nil {
+		b.stopWatcher()
+		b.stopWatcher = nil
+	}
+}
+
 // Serve creates the socket and starts the gRPC server
 func (b *ExcatDevicePlugin) Serve() error {
 	// create socket
@@ -218,20 +446,20 @@ func (b *ExcatDevicePlugin) Serve() error {
 	// start gRPC server
 	log.Debug().Msgf("Start gRPC server with socket %v.", socket)
 
+	// register device plugin with the Kubelet, services have to be
+	// registered before the server is started
+	pluginapi.RegisterDevicePluginServer(b.server, b)
+
 	// StartGrpcServer starts the gRPC server
-	StartGrpcServer := func(b *ExcatDevicePlugin, socket net.Listener) {
+	StartGrpcServer := func(server *grpc.Server, socket net.Listener) {
 		log.Debug().Msg("Starting the gRPC server...")
 
-		err = b.server.Serve(socket)
-		if err != nil {
+		if err := server.Serve(socket); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
 			log.Fatal().Err(err).Msg("Couldn't start gRPC server.")
 		}
 	}
 
-	go StartGrpcServer(b, socket)
-
-	// register device plugin with the Kubelet
-	pluginapi.RegisterDevicePluginServer(b.server, b)
+	go StartGrpcServer(b.server, socket)
 
 	log.Debug().Msgf("Wait for gRPC server to be available. Timeout = %v seconds.", timeout)
 
@@ -291,7 +519,7 @@ func (b *ExcatDevicePlugin) RegisterDevicePluginResource() error {
 	client := pluginapi.NewRegistrationClient(conn)
 
 	// register device plugin for specific resource
-	resourceDNS := rootResourceName + b.resourceName
+	resourceDNS := rdtcat.ResourcePrefix + b.resourceName
 	req := &pluginapi.RegisterRequest{
 		Version:      pluginapi.Version,
 		Endpoint:     path.Base(b.socket),
@@ -320,114 +548,118 @@ func (b *ExcatDevicePlugin) GetDevicePluginOptions(
 
 // ListAndWatch returns a stream of List of Devices
 // Whenever a Device state change or a Device disappears, ListAndWatch
-// returns the new list
+// returns the new list. The stream ends when its context is cancelled or
+// the device plugin is stopped.
 func (b *ExcatDevicePlugin) ListAndWatch(
 	e *pluginapi.Empty, listAndWatchServer pluginapi.DevicePlugin_ListAndWatchServer,
 ) error {
+	// subscribe before sending the initial list, so that no change is missed
+	updates, unsubscribe := b.updates.subscribe()
+	defer unsubscribe()
+
 	if err := b.sendBuffers(listAndWatchServer); err != nil {
 		return err
 	}
 
-	if err := b.watchBuffers(listAndWatchServer); err != nil {
-		return err
-	}
+	for {
+		select {
+		case <-listAndWatchServer.Context().Done():
+			log.Debug().Msgf("ListAndWatch stream of %v closed.", b.resourceName)
 
-	return nil
+			return nil
+		case _, ok := <-updates:
+			if !ok {
+				return nil
+			}
+
+			if err := b.sendBuffers(listAndWatchServer); err != nil {
+				return err
+			}
+		}
+	}
 }
 
-// watchBuffers creates a fsnotify based watcher and adds all configured
-// buffers directories to the watcher. Based on the type of change event,
-// actions like re-reading the buffer configs are initiated.
-func (b *ExcatDevicePlugin) watchBuffers(
-	listAndWatchServer pluginapi.DevicePlugin_ListAndWatchServer,
-) error {
-	watcher, err := fsnotify.NewWatcher()
+// startWatcher creates a watcher on the device plugin directory of kubelet
+// and re-registers the device plugin whenever kubelet restarts, until the
+// device plugin is stopped.
+func (b *ExcatDevicePlugin) startWatcher() {
+	kubeletWatcher, err := b.newKubeletWatcher()
 	if err != nil {
-		return fmt.Errorf("error when starting new fsnotify watcher: %w", err)
+		log.Warn().Err(err).Msgf("cannot watch kubelet restarts, %v won't re-register", b.resourceName)
+
+		return
 	}
-	defer watcher.Close()
 
-	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
 
-	// create parallel thread for checking watcher events.
-	// due to how the RDT kernel driver works, events are only received for tasks
-	// files that a PID is added to. For tasks files that a PID is removed from
-	// (e.g. due to a deleted pod/container) no event is triggered.
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
+	var wg sync.WaitGroup
 
-				log.Debug().Msgf("Change event: %v", event)
+	b.stopWatcher = func() {
+		cancel()
+		wg.Wait()
+	}
 
-				if path.Base(event.Name) == "tasks" {
-					if err := checkTasks(event.Name); err != nil {
-						log.Error().Msgf("%v", err)
-					}
+	wg.Add(1)
 
-					continue
-				}
+	go func() {
+		defer wg.Done()
+		defer kubeletWatcher.Close()
 
-				log.Info().Msgf("Change event in buffers: %v", event)
+		b.watchKubelet(ctx, kubeletWatcher)
+	}()
+}
 
-				// for all changes (tasks files excluded) re-read buffer configs
-				// and advertise them
-				if err := b.updateBuffers(); err != nil {
-					log.Error().Msgf("%v", err)
-				}
+// HasBuffer returns true if the class is advertised as a buffer.
+func (b *ExcatDevicePlugin) HasBuffer(class string) bool {
+	return b.store.snapshot().hasBuffer(class)
+}
 
-				if err := b.sendBuffers(listAndWatchServer); err != nil {
-					log.Error().Msgf("%v", err)
-				}
+// logTaskOwners logs the pods and containers whose tasks are assigned to
+// ExCAT buffers.
+func logTaskOwners(rdtBuffers *rdtcat.Buffers) {
+	owners, err := rdtBuffers.TaskOwners()
+	if err != nil {
+		log.Warn().Err(err).Msg("cannot resolve tasks of the buffers")
 
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
+		return
+	}
 
-				log.Error().Msgf("Error when watching %v: %v", resctrlPath, err)
-			}
+	for _, group := range rdtBuffers.ResctrlGroups {
+		if group.Name == rdtcat.DefaultClass {
+			continue
 		}
-	}()
 
-	// add all buffer directories to the watcher
-	for _, buffer := range b.buffers {
-		bufferPath := resctrlPath + "/" + buffer.name
-		if err := watcher.Add(bufferPath); err != nil {
-			return fmt.Errorf("error when adding buffer directory to watcher: %w", err)
+		for _, owner := range owners[group.Name] {
+			log.Info().Msgf("Buffer %v: %v.", group.Name, owner)
 		}
-
-		log.Debug().Msgf("Added %v to watcher.", bufferPath)
 	}
-
-	<-done
-
-	return nil
 }
 
-// checkTasks reads in the provided tasks file and checks for contained PIDs.
-func checkTasks(file string) error {
+// checkTasks reads in the provided tasks file and logs the pods and
+// containers of the contained PIDs. The tasks file has to be located below
+// the resctrl root.
+func checkTasks(roots rootDirs, file string) error {
 	time.Sleep(1 * time.Second) // sleep to get final container PID
 
-	resctrl := rdtcat.Resctrl{}
-	resctrl.ExcatBuffers = &resctrl
+	resctrl := rdtcat.NewResctrl(roots.resctrl)
+	resctrl.ProcRoot = roots.proc
+
+	bufferName := path.Base(path.Dir(file))
 
-	pids, err := resctrl.GetBufferPids(file)
+	owners, err := resctrl.ResolveTasks(path.Dir(file))
 	if err != nil {
 		return fmt.Errorf("error when checking PIDs in %v: %w", file, err)
 	}
 
-	log.Debug().Msgf("PID %v has been added to %v.", pids, file)
+	for _, owner := range owners {
+		log.Debug().Msgf("Buffer %v: %v.", bufferName, owner)
+	}
 
 	// check if buffer is free again
 	// TODO: for detection of removed PIDs replace this check with a
 	// method that works, e.g. polling after being triggered by
 	// write to other tasks file or freed up ER
-	if pids == nil {
-		bufferName := path.Base(path.Dir(file))
+	if len(owners) == 0 {
 		log.Debug().Msgf("Buffer %v available again.", bufferName)
 	}
 
@@ -437,14 +669,9 @@ func checkTasks(file string) error {
 // sendBuffers loops through the buffers and sends the current list to the
 // ListAndWatch server.
 func (b *ExcatDevicePlugin) sendBuffers(listAndWatchServer pluginapi.DevicePlugin_ListAndWatchServer) error {
-	var devs []*pluginapi.Device
-
-	for _, buffer := range b.buffers {
-		devs = append(devs, &buffer.device)
-		log.Debug().Msgf("Appended buffer %v to devices", buffer.name)
-	}
+	devs := b.store.snapshot().devices()
 
-	log.Debug().Msg("Sending updated devices to ListAndWatchServer")
+	log.Debug().Msgf("Sending %v updated devices to ListAndWatchServer", len(devs))
 
 	if err := listAndWatchServer.Send(&pluginapi.ListAndWatchResponse{Devices: devs}); err != nil {
 		return fmt.Errorf("error when sending updated buffers: %w", err)
@@ -453,75 +680,57 @@ func (b *ExcatDevicePlugin) sendBuffers(listAndWatchServer pluginapi.DevicePlugi
 	return nil
 }
 
-// updateBuffers reads in the current configuration in /sys/fs/rescrtl and
-// extracts the relevant buffers for the given cache level.
-func (b *ExcatDevicePlugin) updateBuffers() error {
-	// read all buffers from /sys/fs/resctrl
-	resctrl := rdtcat.Resctrl{}
-	resctrl.ExcatBuffers = &resctrl
-	allRdtBuffers := rdtcat.Buffers{
-		Resctrl: resctrl,
-	}
-
-	if err := allRdtBuffers.GetAllBuffers(); err != nil {
-		return fmt.Errorf("error when reading buffers from %v: %w", resctrlPath, err)
-	}
+// Update extracts the buffers for the cache levels of the device plugin from
+// a snapshot of all buffers and publishes them to all ListAndWatch streams if
+// the buffers or their health changed.
+func (b *ExcatDevicePlugin) Update(allRdtBuffers *rdtcat.Buffers, report rdtcat.ExclusivityReport) {
+	var changes []rdtcat.Change
 
-	// recreate labels
-	if err := allRdtBuffers.CreateLabels(); err != nil {
-		return fmt.Errorf("error when creating node labels: %w", err)
-	}
+	res := rdtcat.Resource{Name: b.resourceName, CacheLevels: b.cacheLevels, Locked: b.locked}
+	rdtBuffers := res.ExtractBuffers(allRdtBuffers)
 
-	// rm possible old label
-	rmNodeLabel(b.cacheLevel, "")
+	previous, current, _ := b.store.update(func(state *bufferState) (*bufferState, error) {
+		changes = rdtcat.Diff(state.rdtBuffers, rdtBuffers)
+		for _, change := range changes {
+			log.Info().Msgf("%v: %v", b.resourceName, change)
+		}
 
-	// extract buffers for current cache level
-	rdtBuffers := allRdtBuffers.ExtractBuffers(b.cacheLevel)
+		// update buffers, whose health may change due to other classes as well
+		buffers := createBuffers(b.resourceName, rdtBuffers, report)
+		b.markForeignTasks(rdtBuffers, buffers)
 
-	// update buffers and labels
-	if rdtBuffers.ResctrlGroups != nil {
-		var (
-			countBuffers int
-			label        string
-		)
+		return newBufferState(rdtBuffers, buffers), nil
+	})
 
-		switch b.cacheLevel {
-		case cacheLevel2:
-			label = allRdtBuffers.DpL2Label
-		case cacheLevel3:
-			label = allRdtBuffers.DpL3Label
-		}
+	if len(changes) == 0 && equalBuffers(previous.buffers, current.buffers) {
+		log.Debug().Msgf("No changes of buffers for %v.", b.resourceName)
 
-		if err := addNodeLabel(b.cacheLevel, label); err != nil {
-			return fmt.Errorf("error when patching node label: %w", err)
-		}
+		return
+	}
 
-		var buffers []*Buffer
+	if len(current.buffers) > 0 {
+		log.Info().Msgf("Detected %v buffers in %v for %v.", len(current.buffers), b.cfg.resctrl, b.resourceName)
+	} else {
+		log.Info().Msgf("No more buffers for %v configured in %v.", b.resourceName, b.cfg.resctrl)
+	}
 
-		for _, buf := range rdtBuffers.ResctrlGroups {
-			if buf.Name != rdtcat.DefaultClass {
-				dev := pluginapi.Device{
-					ID:     rootResourceName + b.resourceName + "-" + buf.Name,
-					Health: pluginapi.Healthy,
-				}
+	b.updates.publish()
+}
 
-				buffers = append(buffers, &Buffer{
-					device: dev,
-					name:   buf.Name,
-				})
+// equalBuffers returns true if both lists advertise the same devices with the
+// same health.
+func equalBuffers(a, b []*Buffer) bool {
+	if len(a) != len(b) {
+		return false
+	}
 
-				countBuffers++
-			}
+	for ind := range a {
+		if a[ind].device.ID != b[ind].device.ID || a[ind].device.Health != b[ind].device.Health {
+			return false
 		}
-
-		b.buffers = buffers
-
-		log.Info().Msgf("Detected %v buffers in %v for cache level %v.", countBuffers, resctrlPath, b.cacheLevel)
-	} else {
-		log.Info().Msgf("No more buffers for cache level %v configured in %v.", b.cacheLevel, resctrlPath)
 	}
 
-	return nil
+	return true
 }
 
 // Allocate is called during container creation so that the Device
@@ -538,12 +747,41 @@ func (b *ExcatDevicePlugin) Allocate(
 				allocateReq.DevicesIDs)
 		}
 
-		name, err := b.id2name(allocateReq.DevicesIDs[0])
+		buffer, err := b.store.snapshot().buffer(allocateReq.DevicesIDs[0])
 		if err != nil {
 			return nil, err
 		}
 
 		cAllocateResp := pluginapi.ContainerAllocateResponse{}
+
+		// pseudo-locked regions are accessed through their character device,
+		// tasks can't be assigned to the class
+		if buffer.lockDevice != "" {
+			cAllocateResp.Devices = []*pluginapi.DeviceSpec{{
+				ContainerPath: buffer.lockDevice,
+				HostPath:      buffer.lockDevice,
+				Permissions:   "rw",
+			}}
+			log.Debug().Msgf("Added the following device: %v", buffer.lockDevice)
+
+			allocateResp.ContainerResponses = append(allocateResp.ContainerResponses, &cAllocateResp)
+
+			continue
+		}
+
+		name := buffer.name
+
+		// the container carrying the allocation ID in its environment claims
+		// the buffer
+		allocation, err := b.claims.allocate(name)
+		if err != nil {
+			return nil, err
+		}
+
+		cAllocateResp.Envs = map[string]string{rdtcat.AllocationEnv: allocation}
+		log.Debug().Msgf("Added the following environment variable: \"%v\" = \"%v\"",
+			rdtcat.AllocationEnv, allocation)
+
 		cAllocateResp.Annotations = make(map[string]string, 2) //nolint:gomnd // 2 annotations
 
 		// add annotation for containerd and cri-o
@@ -560,17 +798,6 @@ func (b *ExcatDevicePlugin) Allocate(
 	return &allocateResp, nil
 }
 
-// id2name extracts the buffer's name for a given device plugin ID
-func (b *ExcatDevicePlugin) id2name(id string) (string, error) {
-	for _, buffer := range b.buffers {
-		if buffer.device.ID == id {
-			return buffer.name, nil
-		}
-	}
-
-	return "", fmt.Errorf("requested buffer with device ID = %v does not exist", id)
-}
-
 // GetPreferredAllocation returns a preferred set of devices to allocate
 // from a list of available ones. The resulting preferred allocation is not
 // guaranteed to be the allocation ultimately performed by the
@@ -0,0 +1,16 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeviceplugin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Deviceplugin Suite")
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultInventoryAddress = "127.0.0.1:9110"
+	inventoryURLPath        = "/inventory"
+	metricsURLPath          = "/metrics"
+	readHeaderTimeout       = 5 * time.Second
+)
+
+// inventoryPublisher keeps the latest inventory of the node, writes it to a
+// file and serves it via HTTP. It is shared by all device plugins.
+type inventoryPublisher struct {
+	mu        sync.RWMutex
+	file      string // file to write the inventory to, not written if empty
+	inventory *rdtcat.Inventory
+}
+
+// publish updates the inventory with the given buffers and their node labels,
+// and writes it to the file.
+func (p *inventoryPublisher) publish(rdtBuffers *rdtcat.Buffers) {
+	inventory := rdtBuffers.Inventory()
+	inventory.Labels = rdtBuffers.NodeLabels()
+
+	p.mu.Lock()
+	p.inventory = inventory
+	p.mu.Unlock()
+
+	if p.file == "" {
+		return
+	}
+
+	if err := writeInventory(p.file, inventory); err != nil {
+		log.Warn().Err(err).Msgf("cannot write inventory to %v", p.file)
+	}
+}
+
+// writeInventory writes the inventory in JSON format. The file is replaced
+// atomically, so that readers never see a partial inventory.
+func writeInventory(file string, inventory *rdtcat.Inventory) error {
+	data, err := inventory.JSON()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(file), 0o755); err != nil { //nolint:gomnd // world readable directory
+		return fmt.Errorf("error when creating directory of %v: %w", file, err)
+	}
+
+	tmpFile := file + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0o644); err != nil { //nolint:gosec,gomnd // world readable inventory
+		return fmt.Errorf("error when writing %v: %w", tmpFile, err)
+	}
+
+	if err := os.Rename(tmpFile, file); err != nil {
+		return fmt.Errorf("error when replacing %v: %w", file, err)
+	}
+
+	return nil
+}
+
+// ServeHTTP returns the latest inventory in JSON format, or in YAML format if
+// requested with the query parameter format=yaml.
+func (p *inventoryPublisher) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	p.mu.RLock()
+	inventory := p.inventory
+	p.mu.RUnlock()
+
+	if inventory == nil {
+		http.Error(w, "inventory not yet available", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	var (
+		data        []byte
+		err         error
+		contentType = "application/json"
+	)
+
+	if req.URL.Query().Get("format") == "yaml" {
+		data, err = inventory.YAML()
+		contentType = "application/yaml"
+	} else {
+		data, err = inventory.JSON()
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	if _, err := w.Write(data); err != nil {
+		log.Debug().Err(err).Msg("error when sending inventory")
+	}
+}
+
+// serve serves the inventory and the metrics on the given address until the
+// process exits.
+// The address should be local, since the inventory is not authenticated.
+func (p *inventoryPublisher) serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("error when listening on %v: %w", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(inventoryURLPath, p)
+	mux.Handle(metricsURLPath, promhttp.Handler())
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Error().Err(err).Msg("inventory endpoint stopped")
+		}
+	}()
+
+	log.Info().Msgf("Serving inventory on http://%v%v and metrics on http://%v%v.",
+		listener.Addr(), inventoryURLPath, listener.Addr(), metricsURLPath)
+
+	return nil
+}
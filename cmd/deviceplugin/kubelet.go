@@ -0,0 +1,157 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	minBackoff = 1 * time.Second             // first delay between restarts
+	maxBackoff = retryInterval * time.Second // maximum delay between restarts
+)
+
+// newKubeletWatcher returns a watcher on the device plugin directory of
+// kubelet, which contains the kubelet socket and the socket of the device
+// plugin.
+func (b *ExcatDevicePlugin) newKubeletWatcher() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error when starting new fsnotify watcher: %w", err)
+	}
+
+	dir := filepath.Dir(b.socket)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+
+		return nil, fmt.Errorf("error when adding %v to watcher: %w", dir, err)
+	}
+
+	return watcher, nil
+}
+
+// watchKubelet restarts the device plugin whenever kubelet restarts, i.e. the
+// kubelet socket is created again or the socket of the device plugin is
+// deleted, until the context is cancelled.
+func (b *ExcatDevicePlugin) watchKubelet(ctx context.Context, watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if !b.needsRestart(event) {
+				continue
+			}
+
+			log.Info().Msgf("Kubelet restarted (%v), re-registering %v.", event, b.resourceName)
+
+			b.restartWithBackoff(ctx)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Error().Msgf("Error when watching %v: %v", filepath.Dir(b.socket), err)
+		}
+	}
+}
+
+// needsRestart returns true if an event in the device plugin directory
+// indicates that kubelet restarted. The socket of the device plugin is
+// removed by the device plugin itself when restarting, so its removal only
+// counts if the socket is still missing.
+func (b *ExcatDevicePlugin) needsRestart(event fsnotify.Event) bool {
+	dir := filepath.Dir(b.socket)
+
+	switch filepath.Clean(event.Name) {
+	case filepath.Join(dir, path.Base(pluginapi.KubeletSocket)):
+		return event.Has(fsnotify.Create)
+	case filepath.Clean(b.socket):
+		if !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+			return false
+		}
+
+		_, err := os.Stat(b.socket)
+
+		return errors.Is(err, os.ErrNotExist)
+	}
+
+	return false
+}
+
+// restartWithBackoff restarts the device plugin until it succeeds or the
+// context is cancelled. The delay between two attempts doubles up to
+// maxBackoff.
+func (b *ExcatDevicePlugin) restartWithBackoff(ctx context.Context) {
+	for attempt := 0; ; attempt++ {
+		err := b.restart()
+		if err == nil {
+			log.Info().Msgf("successfully re-registered device plugin for %v", b.resourceName)
+
+			return
+		}
+
+		delay := backoff(attempt)
+		log.Warn().Err(err).Msgf("cannot re-register %v, retrying in %v", b.resourceName, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// restart stops the gRPC server, which ends all ListAndWatch streams, and
+// starts and registers the device plugin again.
+func (b *ExcatDevicePlugin) restart() error {
+	if b.server != nil {
+		b.server.Stop()
+	}
+
+	b.initialize()
+
+	if err := b.Serve(); err != nil {
+		return fmt.Errorf("could not start the gRPC server: %w", err)
+	}
+
+	if err := b.RegisterDevicePluginResource(); err != nil {
+		return fmt.Errorf("could not register device plugin for resource %v with Kubelet: %w",
+			b.resourceName, err)
+	}
+
+	return nil
+}
+
+// backoff returns the delay before the next attempt after the given number of
+// failed attempts.
+func backoff(attempt int) time.Duration {
+	delay := minBackoff
+
+	for i := 0; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	return delay
+}
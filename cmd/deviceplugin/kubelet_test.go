@@ -0,0 +1,76 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backoff", func() {
+	It("doubles the delay up to the maximum", func() {
+		Expect(backoff(0)).To(Equal(1 * time.Second))
+		Expect(backoff(1)).To(Equal(2 * time.Second))
+		Expect(backoff(4)).To(Equal(16 * time.Second))
+		Expect(backoff(5)).To(Equal(maxBackoff))
+		Expect(backoff(100)).To(Equal(maxBackoff))
+	})
+})
+
+var _ = Describe("needsRestart", func() {
+	var (
+		dir    string
+		plugin *ExcatDevicePlugin
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		plugin = &ExcatDevicePlugin{socket: filepath.Join(dir, "intel-excat-l3")}
+	})
+
+	It("restarts when the kubelet socket is created", func() {
+		event := fsnotify.Event{Name: filepath.Join(dir, "kubelet.sock"), Op: fsnotify.Create}
+		Expect(plugin.needsRestart(event)).To(BeTrue())
+
+		event.Op = fsnotify.Remove
+		Expect(plugin.needsRestart(event)).To(BeFalse())
+	})
+
+	It("restarts when the socket of the device plugin is deleted", func() {
+		event := fsnotify.Event{Name: plugin.socket, Op: fsnotify.Remove}
+		Expect(plugin.needsRestart(event)).To(BeTrue())
+	})
+
+	It("ignores the socket of the device plugin being replaced", func() {
+		Expect(os.WriteFile(plugin.socket, nil, 0o600)).To(Succeed())
+
+		event := fsnotify.Event{Name: plugin.socket, Op: fsnotify.Remove}
+		Expect(plugin.needsRestart(event)).To(BeFalse())
+
+		event.Op = fsnotify.Create
+		Expect(plugin.needsRestart(event)).To(BeFalse())
+	})
+
+	It("ignores sockets of other device plugins", func() {
+		event := fsnotify.Event{Name: filepath.Join(dir, "intel-excat-l2"), Op: fsnotify.Remove}
+		Expect(plugin.needsRestart(event)).To(BeFalse())
+	})
+
+	It("receives events of the device plugin directory", func() {
+		watcher, err := plugin.newKubeletWatcher()
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(watcher.Close)
+
+		Expect(os.WriteFile(filepath.Join(dir, "kubelet.sock"), nil, 0o600)).To(Succeed())
+
+		var event fsnotify.Event
+		Eventually(watcher.Events).Should(Receive(&event))
+		Expect(plugin.needsRestart(event)).To(BeTrue())
+	})
+})
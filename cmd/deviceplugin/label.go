@@ -6,9 +6,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 
+	"github.com/csl-svc/excat/pkg/rdtcat"
 	"github.com/rs/zerolog/log"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -29,13 +32,84 @@ const (
 
 // rmAllLabels removes all ExCAT related labels.
 func rmAllLabels() {
-	rmNodeLabel(cacheLevel2, "")
-	rmNodeLabel(cacheLevel3, "")
+	for _, res := range rdtcat.Resources {
+		rmNodeLabels(res.Name)
+	}
+}
+
+// patchNodeLabels patches the labels of a resource from the previous to the
+// current values. Only labels whose value changed are added, which replaces
+// the old value, and only labels that are gone are removed, so that unchanged
+// labels never vanish. It returns the labels of the resource on the node
+// afterwards, which keep the previous values of failed patches.
+func patchNodeLabels(resourceName string, previous, current map[string]string) (map[string]string, error) {
+	add, remove := diffLabels(previous, current)
+	patched := make(map[string]string, len(current))
+
+	for suffix, value := range previous {
+		patched[suffix] = value
+	}
+
+	var errs []error
+
+	for suffix, value := range add {
+		if err := addNodeLabel(resourceName, suffix, value); err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		patched[suffix] = value
+	}
+
+	for _, suffix := range remove {
+		labelKey := rootResourceNameJSON + resourceName + suffix
+		if err := patchNodeLabel("remove", labelKey, ""); err != nil {
+			errs = append(errs, fmt.Errorf("error when removing node label: %w", err))
+
+			continue
+		}
+
+		delete(patched, suffix)
+	}
+
+	return patched, errors.Join(errs...)
+}
+
+// diffLabels returns the labels to add since they are new or their value
+// changed, and the suffixes of the labels to remove.
+func diffLabels(previous, current map[string]string) (map[string]string, []string) {
+	add := map[string]string{}
+
+	for suffix, value := range current {
+		if old, ok := previous[suffix]; !ok || old != value {
+			add[suffix] = value
+		}
+	}
+
+	var remove []string
+
+	for suffix := range previous {
+		if _, ok := current[suffix]; !ok {
+			remove = append(remove, suffix)
+		}
+	}
+
+	sort.Strings(remove)
+
+	return add, remove
+}
+
+// rmNodeLabels removes all labels of a given resource from a node.
+func rmNodeLabels(resourceName string) {
+	for _, suffix := range rdtcat.LabelSuffixes {
+		rmNodeLabel(resourceName, suffix, "")
+	}
 }
 
 // addNodeLabel adds a label to a node.
-func addNodeLabel(level int, labelValue string) error {
-	labelKey := fmt.Sprintf("%v%v-l%v", rootResourceNameJSON, resourceBaseName, level)
+func addNodeLabel(resourceName string, suffix string, labelValue string) error {
+	labelKey := rootResourceNameJSON + resourceName + suffix
 
 	if err := patchNodeLabel("add", labelKey, labelValue); err != nil {
 		return fmt.Errorf("error when adding node label: %w", err)
@@ -45,8 +119,8 @@ func addNodeLabel(level int, labelValue string) error {
 }
 
 // rmNodeLabel removes a label from a node.
-func rmNodeLabel(level int, labelValue string) {
-	labelKey := fmt.Sprintf("%v%v-l%v", rootResourceNameJSON, resourceBaseName, level)
+func rmNodeLabel(resourceName string, suffix string, labelValue string) {
+	labelKey := rootResourceNameJSON + resourceName + suffix
 
 	err := patchNodeLabel("remove", labelKey, labelValue)
 	if err != nil {
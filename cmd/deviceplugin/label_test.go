@@ -0,0 +1,37 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("diffLabels", func() {
+	It("adds new and changed labels and removes vanished ones", func() {
+		add, remove := diffLabels(
+			map[string]string{".size": "4", ".count": "2", ".mode": "exclusive"},
+			map[string]string{".size": "4", ".count": "3", ".min": "1"},
+		)
+
+		Expect(add).To(Equal(map[string]string{".count": "3", ".min": "1"}))
+		Expect(remove).To(Equal([]string{".mode"}))
+	})
+
+	It("patches nothing if the labels are unchanged", func() {
+		labels := map[string]string{".size": "4"}
+
+		add, remove := diffLabels(labels, labels)
+
+		Expect(add).To(BeEmpty())
+		Expect(remove).To(BeEmpty())
+	})
+
+	It("removes all labels if none are left", func() {
+		add, remove := diffLabels(map[string]string{".size": "4", ".count": "2"}, nil)
+
+		Expect(add).To(BeEmpty())
+		Expect(remove).To(Equal([]string{".count", ".size"}))
+	})
+})
@@ -0,0 +1,116 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// bufferState is a snapshot of the buffers advertised by a device plugin and
+// the rdtcat buffers they were created from. A state must not be modified once
+// it is stored, updates create a new state.
+type bufferState struct {
+	rdtBuffers *rdtcat.Buffers
+	buffers    []*Buffer
+	byID       map[string]*Buffer
+}
+
+// newBufferState returns a state of the given buffers.
+func newBufferState(rdtBuffers *rdtcat.Buffers, buffers []*Buffer) *bufferState {
+	state := &bufferState{
+		rdtBuffers: rdtBuffers,
+		buffers:    buffers,
+		byID:       make(map[string]*Buffer, len(buffers)),
+	}
+
+	for _, buffer := range buffers {
+		state.byID[buffer.device.ID] = buffer
+	}
+
+	return state
+}
+
+// buffer returns the buffer for a given device plugin ID.
+func (s *bufferState) buffer(id string) (*Buffer, error) {
+	buffer, ok := s.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("device ID %v does not exist", id)
+	}
+
+	return buffer, nil
+}
+
+// hasBuffer returns true if the state contains a buffer of the given class.
+func (s *bufferState) hasBuffer(class string) bool {
+	for _, buffer := range s.buffers {
+		if buffer.name == class {
+			return true
+		}
+	}
+
+	return false
+}
+
+// devices returns the devices of all buffers.
+func (s *bufferState) devices() []*pluginapi.Device {
+	devs := make([]*pluginapi.Device, 0, len(s.buffers))
+
+	for _, buffer := range s.buffers {
+		device := buffer.device
+		devs = append(devs, &device)
+	}
+
+	return devs
+}
+
+// bufferStore keeps the current state of the buffers of a device plugin. The
+// gRPC handlers read consistent snapshots while the watcher replaces the state
+// atomically. Updates are serialized, so that the state is never computed
+// from an outdated snapshot, but readers are not blocked while an update is
+// computed.
+type bufferStore struct {
+	updateMu sync.Mutex   // serializes updates
+	mu       sync.RWMutex // guards state
+	state    *bufferState
+}
+
+// newBufferStore returns a store with the given buffers.
+func newBufferStore(rdtBuffers *rdtcat.Buffers, buffers []*Buffer) *bufferStore {
+	return &bufferStore{state: newBufferState(rdtBuffers, buffers)}
+}
+
+// snapshot returns the current state.
+func (s *bufferStore) snapshot() *bufferState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state
+}
+
+// update computes a new state from the current state and stores it. The
+// state is kept if compute returns an error. It returns the previous and the
+// current state.
+func (s *bufferStore) update(
+	compute func(current *bufferState) (*bufferState, error),
+) (*bufferState, *bufferState, error) {
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+
+	previous := s.snapshot()
+
+	state, err := compute(previous)
+	if err != nil {
+		return previous, previous, err
+	}
+
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+
+	return previous, state, nil
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// testBuffers returns n buffers with the given health.
+func testBuffers(n int, health string) []*Buffer {
+	buffers := make([]*Buffer, 0, n)
+
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("c%v", i)
+		buffers = append(buffers, &Buffer{
+			device: pluginapi.Device{ID: "l3-" + name, Health: health},
+			name:   name,
+		})
+	}
+
+	return buffers
+}
+
+var _ = Describe("bufferStore", func() {
+	var store *bufferStore
+
+	BeforeEach(func() {
+		store = newBufferStore(nil, testBuffers(2, pluginapi.Healthy))
+	})
+
+	It("looks up buffers by device ID", func() {
+		buffer, err := store.snapshot().buffer("l3-c1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(buffer.name).To(Equal("c1"))
+	})
+
+	It("returns an error for unknown device IDs", func() {
+		_, err := store.snapshot().buffer("l3-c9")
+		Expect(err).To(MatchError(ContainSubstring("l3-c9")))
+	})
+
+	It("returns copies of the devices", func() {
+		devs := store.snapshot().devices()
+		Expect(devs).To(HaveLen(2))
+
+		devs[0].Health = pluginapi.Unhealthy
+		Expect(store.snapshot().devices()[0].Health).To(Equal(pluginapi.Healthy))
+	})
+
+	It("replaces the state on update", func() {
+		before := store.snapshot()
+
+		previous, current, err := store.update(func(*bufferState) (*bufferState, error) {
+			return newBufferState(nil, testBuffers(3, pluginapi.Unhealthy)), nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previous).To(BeIdenticalTo(before))
+		Expect(current).To(BeIdenticalTo(store.snapshot()))
+		Expect(current.buffers).To(HaveLen(3))
+
+		// earlier snapshots stay consistent
+		Expect(before.buffers).To(HaveLen(2))
+		_, err = before.buffer("l3-c2")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("keeps the state if an update fails", func() {
+		before := store.snapshot()
+
+		_, current, err := store.update(func(*bufferState) (*bufferState, error) {
+			return nil, errors.New("read failed")
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(current).To(BeIdenticalTo(before))
+		Expect(store.snapshot()).To(BeIdenticalTo(before))
+	})
+
+	It("serializes concurrent updates", func() {
+		const updates = 50
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < updates; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				_, _, err := store.update(func(state *bufferState) (*bufferState, error) {
+					return newBufferState(nil, testBuffers(len(state.buffers)+1, pluginapi.Healthy)), nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}()
+		}
+
+		wg.Wait()
+
+		Expect(store.snapshot().buffers).To(HaveLen(2 + updates))
+	})
+
+	It("serves consistent snapshots while updating", func() {
+		const iterations = 200
+
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				health := pluginapi.Healthy
+				if i%2 == 0 {
+					health = pluginapi.Unhealthy
+				}
+
+				_, _, err := store.update(func(*bufferState) (*bufferState, error) {
+					return newBufferState(nil, testBuffers(1+i%3, health)), nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}()
+
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				for i := 0; i < iterations; i++ {
+					state := store.snapshot()
+					devs := state.devices()
+					Expect(devs).To(HaveLen(len(state.buffers)))
+
+					for _, dev := range devs {
+						buffer, err := state.buffer(dev.ID)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(buffer.device.Health).To(Equal(devs[0].Health))
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+	})
+})
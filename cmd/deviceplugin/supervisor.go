@@ -0,0 +1,280 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// managedPlugin is a running device plugin of a resource.
+type managedPlugin interface {
+	// Update advertises the buffers of the resource in a new snapshot of all
+	// buffers.
+	Update(allRdtBuffers *rdtcat.Buffers, report rdtcat.ExclusivityReport)
+	// HasBuffer returns true if the class is advertised as a buffer.
+	HasBuffer(class string) bool
+	// RefreshHealth checks the advertised buffers for foreign tasks.
+	RefreshHealth()
+	// Drain stops advertising the buffers and stops the device plugin.
+	Drain()
+}
+
+// startFunc starts the device plugin of a resource.
+type startFunc func(
+	res rdtcat.Resource, allRdtBuffers *rdtcat.Buffers, report rdtcat.ExclusivityReport,
+) (managedPlugin, error)
+
+// labelFunc patches the node labels of a resource from the previous to the
+// current values and returns the labels of the resource on the node.
+type labelFunc func(resourceName string, previous, current map[string]string) (map[string]string, error)
+
+// supervisor watches the resctrl root and all classes, starts a device plugin
+// for every resource that has buffers and drains it when all of its buffers
+// are gone. Buffers are read once per change and the snapshot is passed to
+// all running device plugins. The supervisor keeps the node labels of all
+// resources.
+type supervisor struct {
+	cfg         config
+	start       startFunc
+	patchLabels labelFunc
+
+	mu      sync.Mutex
+	plugins map[string]managedPlugin     // running device plugins by resource name
+	labels  map[string]map[string]string // node labels by resource name
+	retry   bool                         // a device plugin failed to start or labels to patch
+}
+
+// newSupervisor returns a supervisor starting device plugins with the given
+// configuration.
+func newSupervisor(cfg config) *supervisor {
+	return &supervisor{
+		cfg:         cfg,
+		start:       cfg.startPlugin,
+		patchLabels: patchNodeLabels,
+		plugins:     make(map[string]managedPlugin),
+		labels:      make(map[string]map[string]string),
+	}
+}
+
+// run watches the resctrl root and all classes and updates the device plugins
+// whenever they change. Changes of tasks are passed to the device plugins
+// advertising the class. Failed device plugins and labels are retried
+// periodically. All device plugins are drained when the context is
+// cancelled.
+func (s *supervisor) run(ctx context.Context) error {
+	watcher, err := newClassWatcher(s.cfg.resctrl)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	defer s.drainAll()
+
+	s.update()
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+
+	defer wg.Wait()
+	defer cancel()
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		s.retryFailed(ctx)
+	}()
+
+	watcher.watch(ctx, s.handleTasks, func(event fsnotify.Event) {
+		log.Info().Msgf("Change event in buffers: %v", event)
+
+		s.update()
+	})
+
+	return nil
+}
+
+// retryFailed updates the device plugins periodically while a device plugin
+// failed to start or labels failed to patch, until the context is cancelled.
+func (s *supervisor) retryFailed(ctx context.Context) {
+	ticker := time.NewTicker(retryInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			retry := s.retry
+			s.mu.Unlock()
+
+			if retry {
+				s.update()
+			}
+		}
+	}
+}
+
+// handleTasks checks the tasks of a class after its tasks file changed and
+// refreshes the health of the device plugins advertising the class. Classes
+// that are no buffers of any device plugin are ignored. The lock is only held
+// to find the device plugins, checking the tasks waits for them to settle.
+func (s *supervisor) handleTasks(class string, event fsnotify.Event) {
+	var plugins []managedPlugin
+
+	s.mu.Lock()
+	for _, plugin := range s.plugins {
+		if plugin.HasBuffer(class) {
+			plugins = append(plugins, plugin)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(plugins) == 0 {
+		return
+	}
+
+	if err := checkTasks(s.cfg.rootDirs, event.Name); err != nil {
+		log.Error().Msgf("%v", err)
+	}
+
+	// foreign tasks change the health of the buffers, a device plugin drained
+	// in the meantime has no buffers left to refresh
+	for _, plugin := range plugins {
+		plugin.RefreshHealth()
+	}
+}
+
+func (s *supervisor) update() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	allRdtBuffers, err := s.cfg.readRdtBuffers()
+	if errors.Is(err, rdtcat.ErrNoClasses) {
+		allRdtBuffers, err = s.cfg.newRdtBuffers(), nil
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("error when reading buffers from %v", s.cfg.resctrl)
+
+		return
+	}
+
+	s.reconcile(allRdtBuffers)
+}
+
+// reconcile starts the device plugins of resources that gained buffers,
+// passes the buffers to the running ones and drains the ones of resources
+// without buffers. The node labels of each resource are patched accordingly.
+// The caller must hold the lock of the supervisor.
+func (s *supervisor) reconcile(allRdtBuffers *rdtcat.Buffers) {
+	report := checkExclusivity(allRdtBuffers)
+
+	s.retry = false
+
+	for _, res := range rdtcat.Resources {
+		hasBuffers := res.ExtractBuffers(allRdtBuffers).HasClasses()
+		plugin, running := s.plugins[res.Name]
+
+		// remove the labels first, so that no more pods are scheduled while
+		// the device plugin drains
+		if !hasBuffers {
+			s.syncLabels(res.Name, nil)
+		}
+
+		switch {
+		case hasBuffers && !running:
+			plugin, err := s.start(res, allRdtBuffers, report)
+			if err != nil {
+				log.Error().Err(err).Msgf("error when starting device plugin for %v, retrying in %v seconds",
+					res.Name, retryInterval)
+
+				s.retry = true
+
+				continue
+			}
+
+			s.plugins[res.Name] = plugin
+
+		case hasBuffers && running:
+			plugin.Update(allRdtBuffers, report)
+
+		case !hasBuffers && running:
+			plugin.Drain()
+			delete(s.plugins, res.Name)
+
+			log.Info().Msgf("stopped device plugin for %v, no more buffers configured", res.Name)
+		}
+
+		if hasBuffers {
+			s.syncLabels(res.Name, allRdtBuffers.ResourceLabels(res))
+		}
+	}
+}
+
+// syncLabels patches the node labels of a resource to the given labels. Labels
+// that failed to patch are retried.
+func (s *supervisor) syncLabels(resourceName string, labels map[string]string) {
+	patched, err := s.patchLabels(resourceName, s.labels[resourceName], labels)
+	if err != nil {
+		log.Error().Err(err).Msgf("error when patching node labels of %v, retrying in %v seconds",
+			resourceName, retryInterval)
+
+		s.retry = true
+	}
+
+	if len(patched) == 0 {
+		delete(s.labels, resourceName)
+	} else {
+		s.labels[resourceName] = patched
+	}
+}
+
+// drainAll removes the node labels and drains all running device plugins.
+func (s *supervisor) drainAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, plugin := range s.plugins {
+		s.syncLabels(name, nil)
+
+		plugin.Drain()
+		delete(s.plugins, name)
+	}
+}
+
+// startPlugin starts the device plugin of a resource.
+func (c config) startPlugin(
+	res rdtcat.Resource, allRdtBuffers *rdtcat.Buffers, report rdtcat.ExclusivityReport,
+) (managedPlugin, error) {
+	rdtBuffers := res.ExtractBuffers(allRdtBuffers)
+
+	// create all buffers as used by the device plugin
+	socketName := fmt.Sprintf("%sintel-%v", pluginapi.DevicePluginPath, res.Name)
+	buffers := createBuffers(res.Name, rdtBuffers, report)
+
+	// create device plugin
+	plugin := NewExcatDevicePlugin(res, socketName, c, rdtBuffers, buffers)
+	plugin.RefreshHealth()
+
+	if err := plugin.Start(); err != nil {
+		return nil, fmt.Errorf("error when creating device plugin for ExCAT resource %v: %w", res.Name, err)
+	}
+
+	log.Info().Msgf("successfully started device plugin for %v %v buffers", len(buffers), res.Name)
+
+	return plugin, nil
+}
@@ -0,0 +1,358 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	"github.com/fsnotify/fsnotify"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	snapshotRoot    = "../../pkg/rdtcat/testdata/resctrl"
+	snapshotSysRoot = "../../pkg/rdtcat/testdata/sys"
+	l3Resource      = "excat-l3"
+)
+
+// writeDefaultOnlyTree writes a resctrl tree with only the default class.
+func writeDefaultOnlyTree(root string) {
+	writeTree(root, map[string]string{
+		"schemata":               "L3:0=fffff;1=fffff\n",
+		"tasks":                  "1\n",
+		"info/L3/num_closids":    "16\n",
+		"info/L3/cbm_mask":       "fffff\n",
+		"info/L3/min_cbm_bits":   "1\n",
+		"info/L3/shareable_bits": "0\n",
+	})
+}
+
+// writeLevelsTree writes a resctrl tree whose default class allocates L3
+// cache, with the class l3 allocating L3 cache and the class l2 allocating
+// L2 cache.
+func writeLevelsTree(root string) {
+	writeTree(root, map[string]string{
+		"schemata":               "L3:0=fff00\n",
+		"size":                   "L3:0=12582912\n",
+		"tasks":                  "1\n",
+		"l3/schemata":            "L3:0=000f0\n",
+		"l3/size":                "L3:0=4194304\n",
+		"l3/tasks":               "",
+		"l2/schemata":            "L2:0=0f\n",
+		"l2/size":                "L2:0=524288\n",
+		"l2/tasks":               "",
+		"info/L2/num_closids":    "8\n",
+		"info/L2/cbm_mask":       "ff\n",
+		"info/L2/min_cbm_bits":   "1\n",
+		"info/L2/shareable_bits": "0\n",
+		"info/L3/num_closids":    "16\n",
+		"info/L3/cbm_mask":       "fffff\n",
+		"info/L3/min_cbm_bits":   "1\n",
+		"info/L3/shareable_bits": "0\n",
+	})
+}
+
+// writeTree writes the given files below the root directory.
+func writeTree(root string, files map[string]string) {
+	for name, data := range files {
+		file := filepath.Join(root, name)
+		Expect(os.MkdirAll(filepath.Dir(file), 0o755)).To(Succeed())
+		Expect(os.WriteFile(file, []byte(data), 0o644)).To(Succeed())
+	}
+}
+
+// fakePlugin records the snapshots it received and whether it was drained.
+type fakePlugin struct {
+	classes   map[string]bool
+	snapshots []*rdtcat.Buffers
+	refreshed int
+	drained   bool
+	onRefresh func()
+}
+
+func (p *fakePlugin) Update(allRdtBuffers *rdtcat.Buffers, _ rdtcat.ExclusivityReport) {
+	p.snapshots = append(p.snapshots, allRdtBuffers)
+}
+
+func (p *fakePlugin) HasBuffer(class string) bool {
+	return p.classes[class]
+}
+
+func (p *fakePlugin) RefreshHealth() {
+	p.refreshed++
+
+	if p.onRefresh != nil {
+		p.onRefresh()
+	}
+}
+
+func (p *fakePlugin) Drain() {
+	p.drained = true
+}
+
+// resource returns the resource with the given name.
+func resource(name string) rdtcat.Resource {
+	for _, res := range rdtcat.Resources {
+		if res.Name == name {
+			return res
+		}
+	}
+
+	Fail("unknown resource " + name)
+
+	return rdtcat.Resource{}
+}
+
+var _ = Describe("supervisor", func() {
+	var (
+		s          *supervisor
+		started    []string
+		plugins    map[string]*fakePlugin
+		failing    map[string]bool
+		nodeLabels map[string]map[string]string
+		patched    []string
+		labelErr   error
+	)
+
+	// readBuffers reads the buffers of the resctrl snapshot.
+	readBuffers := func() *rdtcat.Buffers {
+		allRdtBuffers, err := s.cfg.readRdtBuffers()
+		Expect(err).NotTo(HaveOccurred())
+
+		return allRdtBuffers
+	}
+
+	BeforeEach(func() {
+		started = nil
+		plugins = make(map[string]*fakePlugin)
+		failing = make(map[string]bool)
+		nodeLabels = make(map[string]map[string]string)
+		patched = nil
+		labelErr = nil
+
+		s = newSupervisor(config{rootDirs: rootDirs{resctrl: snapshotRoot, sys: snapshotSysRoot}})
+		s.start = func(res rdtcat.Resource, _ *rdtcat.Buffers, _ rdtcat.ExclusivityReport) (managedPlugin, error) {
+			if failing[res.Name] {
+				return nil, errors.New("kubelet not available")
+			}
+
+			started = append(started, res.Name)
+			plugins[res.Name] = &fakePlugin{classes: map[string]bool{"class0": true}}
+
+			return plugins[res.Name], nil
+		}
+		s.patchLabels = func(name string, previous, current map[string]string) (map[string]string, error) {
+			if labelErr != nil {
+				return previous, labelErr
+			}
+
+			add, remove := diffLabels(previous, current)
+			for suffix := range add {
+				patched = append(patched, "add "+name+suffix)
+			}
+
+			for _, suffix := range remove {
+				patched = append(patched, "remove "+name+suffix)
+			}
+
+			nodeLabels[name] = current
+
+			return current, nil
+		}
+	})
+
+	It("starts device plugins of resources with buffers", func() {
+		s.reconcile(readBuffers())
+
+		Expect(started).To(Equal([]string{l3Resource}))
+		Expect(s.plugins).To(HaveKey(l3Resource))
+		Expect(s.retry).To(BeFalse())
+	})
+
+	It("keeps running device plugins", func() {
+		s.reconcile(readBuffers())
+		s.reconcile(readBuffers())
+
+		Expect(started).To(HaveLen(1))
+		Expect(plugins[l3Resource].drained).To(BeFalse())
+	})
+
+	It("drains device plugins of resources without buffers", func() {
+		s.reconcile(readBuffers())
+		s.reconcile(s.cfg.newRdtBuffers())
+
+		Expect(plugins[l3Resource].drained).To(BeTrue())
+		Expect(s.plugins).To(BeEmpty())
+
+		// the device plugin is started again once buffers reappear
+		s.reconcile(readBuffers())
+
+		Expect(started).To(HaveLen(2))
+		Expect(s.plugins).To(HaveKey(l3Resource))
+	})
+
+	It("retries device plugins that failed to start", func() {
+		failing[l3Resource] = true
+
+		s.reconcile(readBuffers())
+
+		Expect(s.plugins).To(BeEmpty())
+		Expect(s.retry).To(BeTrue())
+
+		failing[l3Resource] = false
+
+		s.reconcile(readBuffers())
+
+		Expect(s.plugins).To(HaveKey(l3Resource))
+		Expect(s.retry).To(BeFalse())
+	})
+
+	It("drains all device plugins", func() {
+		s.reconcile(readBuffers())
+		s.drainAll()
+
+		Expect(plugins[l3Resource].drained).To(BeTrue())
+		Expect(s.plugins).To(BeEmpty())
+	})
+
+	It("drains all device plugins once only the default class is left", func() {
+		s.update()
+		Expect(s.plugins).To(HaveKey(l3Resource))
+
+		s.cfg.resctrl = GinkgoT().TempDir()
+		writeDefaultOnlyTree(s.cfg.resctrl)
+
+		_, err := s.cfg.readRdtBuffers()
+		Expect(err).To(MatchError(rdtcat.ErrNoClasses))
+
+		s.update()
+
+		Expect(plugins[l3Resource].drained).To(BeTrue())
+		Expect(s.plugins).To(BeEmpty())
+	})
+
+	It("passes each snapshot to the running device plugins", func() {
+		s.reconcile(readBuffers())
+
+		allRdtBuffers := readBuffers()
+		s.reconcile(allRdtBuffers)
+
+		Expect(plugins[l3Resource].snapshots).To(HaveLen(1))
+		Expect(plugins[l3Resource].snapshots[0]).To(BeIdenticalTo(allRdtBuffers))
+	})
+
+	It("labels the node for resources with buffers", func() {
+		allRdtBuffers := readBuffers()
+		s.reconcile(allRdtBuffers)
+
+		labels := allRdtBuffers.ResourceLabels(resource(l3Resource))
+		Expect(labels).NotTo(BeEmpty())
+		Expect(nodeLabels).To(HaveKeyWithValue(l3Resource, labels))
+		Expect(s.labels).To(HaveKeyWithValue(l3Resource, labels))
+	})
+
+	It("patches only labels that changed", func() {
+		s.reconcile(readBuffers())
+		patched = nil
+
+		s.reconcile(readBuffers())
+
+		Expect(patched).To(BeEmpty())
+	})
+
+	It("removes the labels before draining a device plugin", func() {
+		s.reconcile(readBuffers())
+		patched = nil
+
+		s.reconcile(s.cfg.newRdtBuffers())
+
+		Expect(patched).NotTo(BeEmpty())
+		Expect(patched).To(HaveEach(HavePrefix("remove " + l3Resource)))
+		Expect(nodeLabels[l3Resource]).To(BeEmpty())
+		Expect(s.labels).To(BeEmpty())
+	})
+
+	It("retries labels that failed to patch", func() {
+		labelErr = errors.New("API server not available")
+
+		s.reconcile(readBuffers())
+
+		Expect(s.plugins).To(HaveKey(l3Resource))
+		Expect(s.labels).To(BeEmpty())
+		Expect(s.retry).To(BeTrue())
+
+		labelErr = nil
+
+		s.reconcile(readBuffers())
+
+		Expect(s.labels).To(HaveKey(l3Resource))
+		Expect(s.retry).To(BeFalse())
+	})
+
+	It("refreshes the health of device plugins advertising a class", func() {
+		s.reconcile(readBuffers())
+
+		s.handleTasks("class1", fsnotify.Event{Name: filepath.Join(snapshotRoot, "class1", "tasks")})
+		Expect(plugins[l3Resource].refreshed).To(BeZero())
+
+		s.handleTasks("class0", fsnotify.Event{Name: filepath.Join(snapshotRoot, "class0", "tasks")})
+		Expect(plugins[l3Resource].refreshed).To(Equal(1))
+	})
+
+	It("doesn't hold the lock while checking the tasks", func() {
+		s.reconcile(readBuffers())
+
+		locked := true
+		plugins[l3Resource].onRefresh = func() {
+			if s.mu.TryLock() {
+				locked = false
+				s.mu.Unlock()
+			}
+		}
+
+		s.handleTasks("class0", fsnotify.Event{Name: filepath.Join(snapshotRoot, "class0", "tasks")})
+		Expect(plugins[l3Resource].refreshed).To(Equal(1))
+		Expect(locked).To(BeFalse())
+	})
+
+	Context("When the default class is the only class of a cache level", func() {
+		BeforeEach(func() {
+			s.cfg.resctrl = GinkgoT().TempDir()
+			s.cfg.sys = GinkgoT().TempDir()
+			writeLevelsTree(s.cfg.resctrl)
+		})
+
+		It("starts no device plugin for the cache level", func() {
+			Expect(os.RemoveAll(filepath.Join(s.cfg.resctrl, "l3"))).To(Succeed())
+
+			allRdtBuffers := readBuffers()
+			Expect(resource(l3Resource).ExtractBuffers(allRdtBuffers).ResctrlGroups).To(
+				ConsistOf(HaveField("Name", rdtcat.DefaultClass)))
+
+			s.reconcile(allRdtBuffers)
+
+			Expect(started).To(Equal([]string{"excat-l2"}))
+			Expect(s.plugins).NotTo(HaveKey(l3Resource))
+			Expect(s.labels).NotTo(HaveKey(l3Resource))
+		})
+
+		It("drains the device plugin once the last class of the level is removed", func() {
+			s.update()
+			Expect(started).To(ConsistOf("excat-l2", l3Resource))
+			Expect(s.labels).To(HaveKey(l3Resource))
+
+			Expect(os.RemoveAll(filepath.Join(s.cfg.resctrl, "l3"))).To(Succeed())
+			s.update()
+
+			Expect(plugins[l3Resource].drained).To(BeTrue())
+			Expect(plugins["excat-l2"].drained).To(BeFalse())
+			Expect(s.plugins).To(HaveLen(1))
+			Expect(s.labels).NotTo(HaveKey(l3Resource))
+		})
+	})
+})
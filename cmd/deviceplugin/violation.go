@@ -0,0 +1,184 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// allocationNonceLen is the number of random bytes of an allocation ID.
+const allocationNonceLen = 8
+
+// metrics of the exclusivity of the buffers
+var (
+	foreignTasksGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "excat_foreign_tasks",
+		Help: "Number of tasks assigned to a buffer that don't belong to the container it is allocated to.",
+	}, []string{"resource", "buffer"})
+	violationsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "excat_exclusivity_violations_total",
+		Help: "Number of times foreign tasks were detected in a buffer that had none before.",
+	}, []string{"resource", "buffer"})
+)
+
+func init() {
+	prometheus.MustRegister(foreignTasksGauge, violationsCounter)
+}
+
+// claimTracker keeps the allocation each buffer is claimed by. The device
+// plugin API doesn't tell which container a buffer is allocated to, so
+// Allocate sets a new allocation ID in the environment of the container and
+// the container whose tasks carry it owns the buffer. Buffers allocated
+// before the device plugin started are owned by the container carrying an
+// allocation of the buffer.
+type claimTracker struct {
+	mu       sync.Mutex
+	claims   map[string]string // allocation ID keyed by class
+	violated map[string]bool   // classes with foreign tasks at the last check
+}
+
+// newClaimTracker returns an empty claimTracker.
+func newClaimTracker() *claimTracker {
+	return &claimTracker{claims: map[string]string{}, violated: map[string]bool{}}
+}
+
+// allocate claims a buffer for a new allocation and returns the allocation
+// ID to set in the environment of the container.
+func (t *claimTracker) allocate(class string) (string, error) {
+	nonce := make([]byte, allocationNonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("cannot create allocation ID of buffer %v: %w", class, err)
+	}
+
+	allocation := class + ":" + hex.EncodeToString(nonce)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.claims[class] = allocation
+
+	return allocation, nil
+}
+
+// check returns the tasks of a buffer not belonging to the container it is
+// allocated to.
+func (t *claimTracker) check(class string, tasks []rdtcat.TaskOwner) []rdtcat.TaskOwner {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	allocation, ok := t.claims[class]
+	if !ok {
+		allocation = recoverAllocation(class, tasks)
+		if allocation != "" {
+			t.claims[class] = allocation
+		}
+	}
+
+	return rdtcat.ForeignTasks(tasks, rdtcat.AllocatedContainer(tasks, allocation))
+}
+
+// recoverAllocation returns the allocation of the buffer carried by the first
+// task running in a container, empty if there is none.
+func recoverAllocation(class string, tasks []rdtcat.TaskOwner) string {
+	for _, task := range tasks {
+		ind := strings.LastIndex(task.Allocation, ":")
+		if task.ContainerID != "" && ind >= 0 && task.Allocation[:ind] == class {
+			return task.Allocation
+		}
+	}
+
+	return ""
+}
+
+// RefreshHealth checks the advertised buffers for foreign tasks, updates
+// their health and publishes changes to all ListAndWatch streams.
+func (b *ExcatDevicePlugin) RefreshHealth() {
+	previous, current, _ := b.store.update(func(state *bufferState) (*bufferState, error) {
+		buffers := cloneBuffers(state.buffers)
+		b.markForeignTasks(state.rdtBuffers, buffers)
+
+		return newBufferState(state.rdtBuffers, buffers), nil
+	})
+
+	if !equalBuffers(previous.buffers, current.buffers) {
+		b.updates.publish()
+	}
+}
+
+// markForeignTasks checks the tasks of the given buffers for foreign tasks,
+// logs and counts violations and sets the health of each buffer. Buffers are
+// unhealthy if they share cache with other classes or have foreign tasks. The
+// buffers must not be stored yet.
+func (b *ExcatDevicePlugin) markForeignTasks(rdtBuffers *rdtcat.Buffers, buffers []*Buffer) {
+	for _, buffer := range buffers {
+		foreign := b.foreignTasks(rdtBuffers, buffer)
+
+		foreignTasksGauge.WithLabelValues(b.resourceName, buffer.name).Set(float64(len(foreign)))
+
+		b.claims.mu.Lock()
+		wasViolated := b.claims.violated[buffer.name]
+		b.claims.violated[buffer.name] = len(foreign) > 0
+		b.claims.mu.Unlock()
+
+		if len(foreign) > 0 && !wasViolated {
+			violationsCounter.WithLabelValues(b.resourceName, buffer.name).Inc()
+		}
+
+		for _, task := range foreign {
+			log.Error().Msgf("Buffer %v not exclusive: foreign task %v.", buffer.device.ID, task)
+		}
+
+		buffer.device.Health = pluginapi.Healthy
+		if buffer.shared || len(foreign) > 0 {
+			buffer.device.Health = pluginapi.Unhealthy
+		}
+	}
+}
+
+// foreignTasks returns the foreign tasks of a buffer created from the given
+// rdtcat buffers. Pseudo-locked regions have no tasks.
+func (b *ExcatDevicePlugin) foreignTasks(rdtBuffers *rdtcat.Buffers, buffer *Buffer) []rdtcat.TaskOwner {
+	if buffer.lockDevice != "" || rdtBuffers == nil {
+		return nil
+	}
+
+	for _, group := range rdtBuffers.ResctrlGroups {
+		if group.Name != buffer.name {
+			continue
+		}
+
+		tasks, err := rdtBuffers.ResolveTasks(group.Path)
+		if err != nil {
+			log.Warn().Err(err).Msgf("cannot resolve tasks of buffer %v", buffer.device.ID)
+
+			return nil
+		}
+
+		return b.claims.check(buffer.name, tasks)
+	}
+
+	return nil
+}
+
+// cloneBuffers returns a copy of the buffers that can be modified without
+// affecting the advertised buffers.
+func cloneBuffers(buffers []*Buffer) []*Buffer {
+	clones := make([]*Buffer, 0, len(buffers))
+
+	for _, buffer := range buffers {
+		clone := *buffer
+		clones = append(clones, &clone)
+	}
+
+	return clones
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	ownerContainer   = "4f1c2b9e8d7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e"
+	foreignContainer = "9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d"
+)
+
+var _ = Describe("claimTracker", func() {
+	var claims *claimTracker
+
+	BeforeEach(func() {
+		claims = newClaimTracker()
+	})
+
+	It("creates a new allocation ID per allocation", func() {
+		first, err := claims.allocate("c0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first).To(HavePrefix("c0:"))
+
+		second, err := claims.allocate("c0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).NotTo(Equal(first))
+	})
+
+	It("reports the tasks of containers the buffer isn't allocated to", func() {
+		allocation, err := claims.allocate("c0")
+		Expect(err).NotTo(HaveOccurred())
+
+		// the foreign container has the lower PID
+		tasks := []rdtcat.TaskOwner{
+			{PID: "10", ContainerID: foreignContainer},
+			{PID: "20", ContainerID: ownerContainer, Allocation: allocation},
+			{PID: "21", ContainerID: ownerContainer},
+		}
+
+		Expect(claims.check("c0", tasks)).To(ConsistOf(HaveField("PID", "10")))
+	})
+
+	It("reports all tasks until the allocated container runs", func() {
+		_, err := claims.allocate("c0")
+		Expect(err).NotTo(HaveOccurred())
+
+		tasks := []rdtcat.TaskOwner{{PID: "10", ContainerID: foreignContainer}}
+		Expect(claims.check("c0", tasks)).To(HaveLen(1))
+	})
+
+	It("reports the previous owner after the buffer is allocated again", func() {
+		previous, err := claims.allocate("c0")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = claims.allocate("c0")
+		Expect(err).NotTo(HaveOccurred())
+
+		tasks := []rdtcat.TaskOwner{{PID: "20", ContainerID: ownerContainer, Allocation: previous}}
+		Expect(claims.check("c0", tasks)).To(HaveLen(1))
+	})
+
+	It("recovers allocations made before the device plugin started", func() {
+		tasks := []rdtcat.TaskOwner{
+			{PID: "1", Cgroup: "/init.scope", Allocation: "c0:0011223344556677"},
+			{PID: "10", ContainerID: foreignContainer, Allocation: "c1:8899aabbccddeeff"},
+			{PID: "20", ContainerID: ownerContainer, Allocation: "c0:0011223344556677"},
+		}
+
+		Expect(claims.check("c0", tasks)).To(ConsistOf(HaveField("PID", "1"), HaveField("PID", "10")))
+		Expect(claims.check("c0", tasks[2:])).To(BeEmpty())
+	})
+
+	It("reports all tasks of buffers never allocated", func() {
+		tasks := []rdtcat.TaskOwner{{PID: "10", ContainerID: foreignContainer}}
+		Expect(claims.check("c0", tasks)).To(HaveLen(1))
+		Expect(claims.check("c0", nil)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Allocate", func() {
+	It("sets the allocation ID in the environment of the container", func() {
+		plugin := &ExcatDevicePlugin{
+			resourceName: "l3",
+			store:        newBufferStore(nil, testBuffers(2, pluginapi.Healthy)),
+			claims:       newClaimTracker(),
+		}
+
+		resp, err := plugin.Allocate(context.Background(), &pluginapi.AllocateRequest{
+			ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"l3-c1"}}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.ContainerResponses).To(HaveLen(1))
+
+		envs := resp.ContainerResponses[0].Envs
+		Expect(envs).To(HaveKeyWithValue(rdtcat.AllocationEnv, HavePrefix("c1:")))
+		Expect(resp.ContainerResponses[0].Annotations).To(HaveKeyWithValue(rdtAnnotation, "c1"))
+
+		tasks := []rdtcat.TaskOwner{
+			{PID: "10", ContainerID: foreignContainer},
+			{PID: "20", ContainerID: ownerContainer, Allocation: envs[rdtcat.AllocationEnv]},
+		}
+		Expect(plugin.claims.check("c1", tasks)).To(ConsistOf(HaveField("PID", "10")))
+	})
+})
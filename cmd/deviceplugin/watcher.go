@@ -0,0 +1,179 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// classWatcher watches the resctrl root and the directories of all classes.
+// Classes created or removed at runtime show up as events in the resctrl
+// root, upon which the set of watched class directories is rebuilt.
+type classWatcher struct {
+	*fsnotify.Watcher
+	root    string
+	classes map[string]bool // watched class directories
+}
+
+// newClassWatcher returns a watcher for the given resctrl root and all of its
+// classes.
+func newClassWatcher(root string) (*classWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error when starting new fsnotify watcher: %w", err)
+	}
+
+	w := &classWatcher{
+		Watcher: watcher,
+		root:    filepath.Clean(root),
+		classes: make(map[string]bool),
+	}
+
+	if err := w.Add(w.root); err != nil {
+		w.Close()
+
+		return nil, fmt.Errorf("error when adding %v to watcher: %w", w.root, err)
+	}
+
+	if _, err := w.sync(); err != nil {
+		w.Close()
+
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// sync adds the directories of new classes to the watcher and removes the
+// ones of deleted classes. It returns true if the set of classes changed.
+func (w *classWatcher) sync() (bool, error) {
+	names, err := rdtcat.NewResctrl(w.root).GetClassNames()
+	if err != nil {
+		return false, err
+	}
+
+	current := make(map[string]bool, len(names))
+	changed := false
+
+	for _, name := range names {
+		// the default class is the root, which is always watched
+		if name == rdtcat.DefaultClass {
+			continue
+		}
+
+		current[name] = true
+
+		if w.classes[name] {
+			continue
+		}
+
+		classPath := path.Join(w.root, name)
+		if err := w.Add(classPath); err != nil {
+			// the class may have been removed in the meantime
+			log.Warn().Err(err).Msgf("cannot add %v to watcher", classPath)
+
+			delete(current, name)
+
+			continue
+		}
+
+		changed = true
+
+		log.Debug().Msgf("Added %v to watcher.", classPath)
+	}
+
+	for name := range w.classes {
+		if current[name] {
+			continue
+		}
+
+		changed = true
+
+		// watches of removed directories are removed by fsnotify already
+		classPath := path.Join(w.root, name)
+		if err := w.Remove(classPath); err != nil && !errors.Is(err, fsnotify.ErrNonExistentWatch) {
+			log.Warn().Err(err).Msgf("cannot remove %v from watcher", classPath)
+		}
+
+		log.Debug().Msgf("Removed %v from watcher.", classPath)
+	}
+
+	w.classes = current
+
+	return changed, nil
+}
+
+// isRootEvent returns true if the event concerns an entry of the resctrl
+// root, e.g. a class directory being created or removed.
+func (w *classWatcher) isRootEvent(event fsnotify.Event) bool {
+	return filepath.Dir(event.Name) == w.root
+}
+
+// changesClasses returns true if the event may have created or removed a
+// class, i.e. an entry of the resctrl root was created, removed or renamed.
+func (w *classWatcher) changesClasses(event fsnotify.Event) bool {
+	return w.isRootEvent(event) &&
+		(event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename))
+}
+
+// class returns the name of the class an event belongs to.
+func (w *classWatcher) class(event fsnotify.Event) string {
+	dir := filepath.Dir(event.Name)
+	if dir == w.root {
+		return rdtcat.DefaultClass
+	}
+
+	return filepath.Base(dir)
+}
+
+// watch handles the events of the watcher until the context is cancelled.
+// Events of tasks files are passed to onTasks with the name of their class,
+// all other events to onChange. Classes created or removed at runtime are
+// added to or removed from the watcher before onChange is called.
+func (w *classWatcher) watch(
+	ctx context.Context, onTasks func(class string, event fsnotify.Event), onChange func(event fsnotify.Event),
+) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+
+			log.Debug().Msgf("Change event: %v", event)
+
+			if path.Base(event.Name) == "tasks" {
+				onTasks(w.class(event), event)
+
+				continue
+			}
+
+			if w.changesClasses(event) {
+				if _, err := w.sync(); err != nil {
+					log.Error().Msgf("%v", err)
+				}
+			}
+
+			onChange(event)
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+
+			log.Error().Msgf("Error when watching %v: %v", w.root, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	"github.com/fsnotify/fsnotify"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// addClass creates a class directory in a fake resctrl tree.
+func addClass(root, name string) {
+	classPath := filepath.Join(root, name)
+	Expect(os.Mkdir(classPath, 0o755)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(classPath, "schemata"), []byte("L3:0=ff\n"), 0o644)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(classPath, "tasks"), nil, 0o644)).To(Succeed())
+}
+
+// createClass creates a class directory in a fake resctrl tree at once, like
+// the kernel does, by moving a complete class directory into the root.
+func createClass(root, name string) {
+	staging := GinkgoT().TempDir()
+	addClass(staging, name)
+
+	Expect(os.Rename(filepath.Join(staging, name), filepath.Join(root, name))).To(Succeed())
+}
+
+// receiveEvent waits for an event of the watcher concerning the given file.
+func receiveEvent(watcher *classWatcher, name string) fsnotify.Event {
+	var event fsnotify.Event
+
+	Eventually(func() string {
+		select {
+		case event = <-watcher.Events:
+			return event.Name
+		case <-time.After(10 * time.Millisecond):
+			return ""
+		}
+	}).Should(Equal(name))
+
+	return event
+}
+
+var _ = Describe("classWatcher", func() {
+	var (
+		root    string
+		watcher *classWatcher
+	)
+
+	BeforeEach(func() {
+		root = GinkgoT().TempDir()
+
+		Expect(os.WriteFile(filepath.Join(root, "schemata"), []byte("L3:0=fff\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "tasks"), nil, 0o644)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(root, "info", "L3"), 0o755)).To(Succeed())
+		addClass(root, "c0")
+		addClass(root, "c1")
+
+		var err error
+		watcher, err = newClassWatcher(root)
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(watcher.Close)
+	})
+
+	It("watches the directories of all classes", func() {
+		Expect(watcher.classes).To(Equal(map[string]bool{"c0": true, "c1": true}))
+
+		schemata := filepath.Join(root, "c1", "schemata")
+		Expect(os.WriteFile(schemata, []byte("L3:0=f\n"), 0o644)).To(Succeed())
+
+		event := receiveEvent(watcher, schemata)
+		Expect(watcher.isRootEvent(event)).To(BeFalse())
+		Expect(watcher.class(event)).To(Equal("c1"))
+	})
+
+	It("watches the resctrl root", func() {
+		schemata := filepath.Join(root, "schemata")
+		Expect(os.WriteFile(schemata, []byte("L3:0=ff0\n"), 0o644)).To(Succeed())
+
+		event := receiveEvent(watcher, schemata)
+		Expect(watcher.isRootEvent(event)).To(BeTrue())
+		Expect(watcher.changesClasses(event)).To(BeFalse())
+		Expect(watcher.class(event)).To(Equal(rdtcat.DefaultClass))
+	})
+
+	Context("when handling events", func() {
+		// change is an event passed to onChange and the classes watched at
+		// that time
+		type change struct {
+			event   fsnotify.Event
+			classes []string
+		}
+
+		var (
+			changes chan change
+			tasks   chan string
+		)
+
+		// receiveChange waits for a change concerning the given file.
+		receiveChange := func(name string) change {
+			var c change
+
+			Eventually(func() string {
+				select {
+				case c = <-changes:
+					return c.event.Name
+				case <-time.After(10 * time.Millisecond):
+					return ""
+				}
+			}).Should(Equal(name))
+
+			return c
+		}
+
+		BeforeEach(func() {
+			changes = make(chan change, 100)
+			tasks = make(chan string, 100)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+
+			go func() {
+				defer close(done)
+
+				watcher.watch(ctx,
+					func(class string, _ fsnotify.Event) {
+						tasks <- class
+					},
+					func(event fsnotify.Event) {
+						// called by the event loop, so the classes are not modified concurrently
+						classes := make([]string, 0, len(watcher.classes))
+						for name := range watcher.classes {
+							classes = append(classes, name)
+						}
+
+						sort.Strings(classes)
+						changes <- change{event: event, classes: classes}
+					})
+			}()
+
+			DeferCleanup(func() {
+				cancel()
+				<-done
+			})
+		})
+
+		It("passes changes of the resctrl root", func() {
+			schemata := filepath.Join(root, "schemata")
+			Expect(os.WriteFile(schemata, []byte("L3:0=ff0\n"), 0o644)).To(Succeed())
+
+			c := receiveChange(schemata)
+			Expect(c.classes).To(Equal([]string{"c0", "c1"}))
+		})
+
+		It("passes tasks events with their class", func() {
+			Expect(os.WriteFile(filepath.Join(root, "c1", "tasks"), []byte("1\n"), 0o644)).To(Succeed())
+			Eventually(tasks).Should(Receive(Equal("c1")))
+
+			Expect(os.WriteFile(filepath.Join(root, "tasks"), []byte("1\n"), 0o644)).To(Succeed())
+			Eventually(tasks).Should(Receive(Equal(rdtcat.DefaultClass)))
+		})
+
+		It("watches classes created at runtime", func() {
+			createClass(root, "c2")
+
+			c := receiveChange(filepath.Join(root, "c2"))
+			Expect(c.classes).To(Equal([]string{"c0", "c1", "c2"}))
+
+			// events of the new class are received
+			schemata := filepath.Join(root, "c2", "schemata")
+			Expect(os.WriteFile(schemata, []byte("L3:0=f00\n"), 0o644)).To(Succeed())
+			receiveChange(schemata)
+
+			Expect(os.WriteFile(filepath.Join(root, "c2", "tasks"), []byte("1\n"), 0o644)).To(Succeed())
+			Eventually(tasks).Should(Receive(Equal("c2")))
+		})
+
+		It("stops watching classes removed at runtime", func() {
+			Expect(os.RemoveAll(filepath.Join(root, "c0"))).To(Succeed())
+
+			c := receiveChange(filepath.Join(root, "c0"))
+			Expect(c.classes).To(Equal([]string{"c1"}))
+		})
+	})
+
+	It("reports no change if the classes are unchanged", func() {
+		changed, err := watcher.sync()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+	})
+
+	It("fails without a resctrl filesystem", func() {
+		_, err := newClassWatcher(GinkgoT().TempDir())
+		Expect(err).To(MatchError(rdtcat.ErrRDTUnsupported))
+	})
+})
@@ -0,0 +1,146 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+)
+
+const (
+	tabMinWidth = 0
+	tabWidth    = 8
+	tabPadding  = 2
+)
+
+// newTabWriter returns a writer aligning tab separated columns.
+func newTabWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, tabMinWidth, tabWidth, tabPadding, ' ', 0)
+}
+
+// inspect shows the classes and capabilities read from resctrl. The JSON
+// output is the inventory of the node.
+func inspect(cfg config, rdtBuffers *rdtcat.Buffers, w io.Writer) error {
+	inventory := rdtBuffers.Inventory()
+
+	if cfg.output == outputJSON {
+		return writeJSON(w, inventory)
+	}
+
+	tw := newTabWriter(w)
+	fmt.Fprintln(tw, "CLASS\tMODE\tCACHE LEVEL\tSIZE (KiB)\tCACHE IDS")
+
+	for _, class := range inventory.Classes {
+		cacheIDs := make([]string, 0, len(class.CacheIDs))
+		for _, cacheID := range class.CacheIDs {
+			if cacheID.CodeMask != "" {
+				cacheIDs = append(cacheIDs, fmt.Sprintf("%v:%v=%v/%v(%v/%v KiB)", cacheID.CacheLevel, cacheID.ID,
+					cacheID.CodeMask, cacheID.DataMask, cacheID.CodeSizeKib, cacheID.DataSizeKib))
+
+				continue
+			}
+
+			cacheIDs = append(cacheIDs, fmt.Sprintf("%v:%v=%v(%v KiB)",
+				cacheID.CacheLevel, cacheID.ID, cacheID.Mask, cacheID.SizeKib))
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n",
+			class.Name, class.Mode, class.CacheLevel, class.SizeKib, strings.Join(cacheIDs, " "))
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "CACHE LEVEL\tCLOS\tCBM MASK\tMIN CBM BITS\tSHAREABLE BITS\tFREE CLOS\tFREE BITS\tFREE BUFFERS")
+
+	for _, caps := range inventory.Capabilities {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", caps.CacheLevel, caps.NumClosids, caps.CbmMask,
+			caps.MinCbmBits, caps.ShareableBits, caps.FreeClosids, caps.FreeBits, caps.FreeBuffers)
+	}
+
+	return tw.Flush()
+}
+
+// validate shows the findings of the validation and fails if any finding is
+// an error.
+func validate(cfg config, rdtBuffers *rdtcat.Buffers, w io.Writer) error {
+	findings := rdtBuffers.Validate()
+
+	if cfg.output == outputJSON {
+		if findings == nil {
+			findings = []rdtcat.Finding{}
+		}
+
+		if err := writeJSON(w, findings); err != nil {
+			return err
+		}
+	} else {
+		for _, finding := range findings {
+			fmt.Fprintln(w, finding)
+		}
+
+		if len(findings) == 0 {
+			fmt.Fprintln(w, "no issues found")
+		}
+	}
+
+	if rdtcat.HasErrors(findings) {
+		return errValidation
+	}
+
+	return nil
+}
+
+// labels shows the node labels the device plugin would add.
+func labels(cfg config, rdtBuffers *rdtcat.Buffers, w io.Writer) error {
+	if err := rdtBuffers.CreateLabels(); err != nil {
+		return fmt.Errorf("error when creating node labels: %w", err)
+	}
+
+	nodeLabels := rdtBuffers.NodeLabels()
+
+	if cfg.output == outputJSON {
+		return writeJSON(w, nodeLabels)
+	}
+
+	names := make([]string, 0, len(nodeLabels))
+	for name := range nodeLabels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%v=%v\n", name, nodeLabels[name])
+	}
+
+	return nil
+}
+
+// tasks shows the PIDs assigned to each class with their pods and
+// containers.
+func tasks(cfg config, rdtBuffers *rdtcat.Buffers, w io.Writer) error {
+	owners, err := rdtBuffers.TaskOwners()
+	if err != nil {
+		return err
+	}
+
+	if cfg.output == outputJSON {
+		return writeJSON(w, owners)
+	}
+
+	tw := newTabWriter(w)
+	fmt.Fprintln(tw, "CLASS\tPID\tPOD UID\tCONTAINER ID")
+
+	for _, group := range rdtBuffers.ResctrlGroups {
+		for _, owner := range owners[group.Name] {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", group.Name, owner.PID, owner.PodUID, owner.ContainerID)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,172 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+// excatctl shows what ExCAT reads from resctrl on a node and what it would
+// advertise. It reads from the live resctrl filesystem or from a snapshot,
+// e.g. of a support bundle.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// output formats
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// exit codes
+const (
+	exitFailure = 1 // command failed or validation found errors
+	exitUsage   = 2 // invalid command or flags
+)
+
+// errValidation is returned if the validation found errors.
+var errValidation = errors.New("validation failed")
+
+// config keeps the flags common to all commands
+type config struct {
+	resctrl string
+	sys     string
+	proc    string
+	output  string
+	debug   bool
+}
+
+// command keeps a subcommand and its description
+type command struct {
+	name        string
+	description string
+	run         func(cfg config, rdtBuffers *rdtcat.Buffers, w io.Writer) error
+}
+
+var commands = []command{
+	{name: "inspect", description: "show classes, modes, bitmasks and sizes per cache ID", run: inspect},
+	{name: "validate", description: "check for overlaps, mixed cache levels, CDP and size issues", run: validate},
+	{name: "labels", description: "show the node labels the device plugin would add", run: labels},
+	{name: "tasks", description: "show the PIDs assigned to each class with their pods and containers", run: tasks},
+}
+
+func usage(w io.Writer, program string) {
+	fmt.Fprintf(w, "Usage: %v <command> [flags]\n\nCommands:\n", path.Base(program))
+
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10v %v\n", cmd.name, cmd.description)
+	}
+
+	fmt.Fprintf(w, "\nRun '%v <command> -h' to list the flags.\n", path.Base(program))
+}
+
+func parseFlags(name string, args []string) (config, error) {
+	var cfg config
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&cfg.resctrl, "resctrl-root", rdtcat.RdtctrlPath, ""+
+		"root directory of the resctrl filesystem, e.g. a snapshot of /sys/fs/resctrl")
+	flags.StringVar(&cfg.sys, "sys-root", rdtcat.SysfsPath, ""+
+		"root directory of sysfs used to read the CPU cache hierarchy")
+	flags.StringVar(&cfg.proc, "proc-root", rdtcat.ProcfsPath, ""+
+		"root directory of procfs used to map tasks to pods and containers")
+	flags.StringVar(&cfg.output, "o", outputText, ""+
+		"output format, text or json")
+	flags.BoolVar(&cfg.debug, "debug", false, "sets log level to debug")
+
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if cfg.output != outputText && cfg.output != outputJSON {
+		return cfg, fmt.Errorf("unsupported output format %v", cfg.output)
+	}
+
+	return cfg, nil
+}
+
+func main() {
+	os.Exit(run(os.Args, os.Stdout, os.Stderr))
+}
+
+// run runs the command given in args, which start with the program name, and
+// returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 2 { //nolint:gomnd // program name and command
+		usage(stderr, args[0])
+
+		return exitUsage
+	}
+
+	var cmd *command
+
+	for ind := range commands {
+		if commands[ind].name == args[1] {
+			cmd = &commands[ind]
+		}
+	}
+
+	if cmd == nil {
+		usage(stderr, args[0])
+
+		return exitUsage
+	}
+
+	cfg, err := parseFlags(cmd.name, args[2:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return exitUsage
+	}
+
+	loglevel := zerolog.WarnLevel
+	if cfg.debug {
+		loglevel = zerolog.DebugLevel
+	}
+
+	zerolog.SetGlobalLevel(loglevel)
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: stderr})
+
+	rdtBuffers := rdtcat.NewBuffers(cfg.resctrl)
+	rdtBuffers.SysRoot = cfg.sys
+	rdtBuffers.ProcRoot = cfg.proc
+
+	// a node without classes can be inspected nevertheless
+	if err := rdtBuffers.GetAllBuffers(); err != nil && !errors.Is(err, rdtcat.ErrNoClasses) {
+		fmt.Fprintf(stderr, "error when reading buffers from %v: %v\n", cfg.resctrl, err)
+
+		return exitFailure
+	}
+
+	if err := cmd.run(cfg, rdtBuffers, stdout); err != nil {
+		if !errors.Is(err, errValidation) {
+			fmt.Fprintln(stderr, err)
+		}
+
+		return exitFailure
+	}
+
+	return 0
+}
+
+// writeJSON writes a value in indented JSON format.
+func writeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("error when writing JSON: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing/fstest"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("excatctl", func() {
+	var (
+		snapshot       fstest.MapFS
+		root, sysRoot  string
+		stdout, stderr *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		snapshot = fstest.MapFS{
+			"schemata":               {Data: []byte("L3:0=ff0;1=ff0\n")},
+			"size":                   {Data: []byte("L3:0=10485760;1=10485760\n")},
+			"tasks":                  {Data: []byte("1\n")},
+			"c0/schemata":            {Data: []byte("L3:0=3;1=3\n")},
+			"c0/size":                {Data: []byte("L3:0=2621440;1=2621440\n")},
+			"c0/mode":                {Data: []byte("exclusive\n")},
+			"c0/tasks":               {Data: []byte("")},
+			"c1/schemata":            {Data: []byte("L3:0=c;1=c\n")},
+			"c1/size":                {Data: []byte("L3:0=2621440;1=2621440\n")},
+			"c1/mode":                {Data: []byte("exclusive\n")},
+			"c1/tasks":               {Data: []byte("")},
+			"info/L3/num_closids":    {Data: []byte("16\n")},
+			"info/L3/cbm_mask":       {Data: []byte("fff\n")},
+			"info/L3/min_cbm_bits":   {Data: []byte("1\n")},
+			"info/L3/shareable_bits": {Data: []byte("0\n")},
+		}
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	})
+
+	// the snapshot is copied to a directory passed with -resctrl-root like
+	// a snapshot of a support bundle
+	JustBeforeEach(func() {
+		root = GinkgoT().TempDir()
+		Expect(os.CopyFS(root, snapshot)).To(Succeed())
+		sysRoot = GinkgoT().TempDir()
+	})
+
+	// excatctl runs a command on the snapshot and returns the exit code.
+	excatctl := func(command string, flags ...string) int {
+		args := append([]string{"excatctl", command, "-resctrl-root", root, "-sys-root", sysRoot}, flags...)
+
+		return run(args, stdout, stderr)
+	}
+
+	Context("When inspecting a snapshot", func() {
+		It("should show the classes as text", func() {
+			Expect(excatctl("inspect")).To(BeZero(), stderr.String())
+			Expect(stdout.String()).To(MatchRegexp(`c0\s+exclusive\s+L3\s+2560\s+L3:0=0x3\(2560 KiB\) L3:1=0x3\(2560 KiB\)`))
+			Expect(stdout.String()).To(MatchRegexp(`c1\s+exclusive\s+L3\s+2560`))
+		})
+
+		It("should show the inventory of the snapshot as JSON", func() {
+			Expect(excatctl("inspect", "-o", "json")).To(BeZero(), stderr.String())
+
+			var inventory rdtcat.Inventory
+			Expect(json.Unmarshal(stdout.Bytes(), &inventory)).To(Succeed())
+			Expect(inventory.Root).To(Equal(root))
+			Expect(inventory.Classes).To(ContainElements(
+				And(HaveField("Name", "c0"), HaveField("Mode", rdtcat.ModeExclusive)),
+				And(HaveField("Name", "c1"), HaveField("SizeKib", 2560)),
+			))
+		})
+
+		It("should fail if the snapshot is not a resctrl tree", func() {
+			root = GinkgoT().TempDir()
+			Expect(excatctl("inspect")).To(Equal(exitFailure))
+			Expect(stderr.String()).To(ContainSubstring("error when reading buffers from " + root))
+		})
+	})
+
+	Context("When validating a snapshot", func() {
+		It("should succeed without errors", func() {
+			Expect(excatctl("validate")).To(BeZero(), stderr.String())
+			Expect(stdout.String()).To(Equal("no issues found\n"))
+		})
+
+		Context("When buffers overlap", func() {
+			BeforeEach(func() {
+				snapshot["c1/schemata"] = &fstest.MapFile{Data: []byte("L3:0=30;1=30\n")}
+			})
+
+			It("should exit with failure and show the findings as text", func() {
+				Expect(excatctl("validate")).To(Equal(exitFailure))
+				Expect(stdout.String()).To(HavePrefix(rdtcat.SeverityError + ": "))
+				Expect(stderr.String()).NotTo(ContainSubstring(errValidation.Error()))
+			})
+
+			It("should exit with failure and show the findings as JSON", func() {
+				Expect(excatctl("validate", "-o", "json")).To(Equal(exitFailure))
+
+				var findings []rdtcat.Finding
+				Expect(json.Unmarshal(stdout.Bytes(), &findings)).To(Succeed())
+				Expect(findings).To(ContainElement(And(
+					HaveField("Kind", rdtcat.FindingOverlap), HaveField("Severity", rdtcat.SeverityError))))
+			})
+		})
+	})
+
+	Context("When showing the labels of a snapshot", func() {
+		It("should show the labels as text", func() {
+			Expect(excatctl("labels")).To(BeZero(), stderr.String())
+			Expect(stdout.String()).To(Equal(
+				"intel.com/excat-l3=2560\nintel.com/excat-l3-free=7\nintel.com/excat-l3-max=2560\n"))
+		})
+
+		It("should show the labels as JSON", func() {
+			Expect(excatctl("labels", "-o", "json")).To(BeZero(), stderr.String())
+
+			var labels map[string]string
+			Expect(json.Unmarshal(stdout.Bytes(), &labels)).To(Succeed())
+			Expect(labels).To(Equal(map[string]string{
+				"intel.com/excat-l3":      "2560",
+				"intel.com/excat-l3-free": "7",
+				"intel.com/excat-l3-max":  "2560",
+			}))
+		})
+	})
+
+	It("should reject unknown commands and output formats", func() {
+		Expect(run([]string{"excatctl", "unknown"}, stdout, stderr)).To(Equal(exitUsage))
+		Expect(excatctl("inspect", "-o", "yaml")).To(Equal(exitUsage))
+	})
+})
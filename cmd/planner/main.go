@@ -0,0 +1,102 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+// planner writes an RDT configuration for containerd or cri-o with a given
+// number of exclusive buffers, planned on the caches of the local node.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultCacheLevel = 3
+
+// config keeps the configuration of the planner
+type config struct {
+	resctrl string
+	sys     string
+	output  string
+	request rdtcat.PlanRequest
+	debug   bool
+}
+
+func parseFlags(cfg *config) {
+	flag.StringVar(&cfg.resctrl, "resctrl-root", rdtcat.RdtctrlPath, ""+
+		"root directory of the resctrl filesystem, e.g. a snapshot of /sys/fs/resctrl")
+	flag.StringVar(&cfg.sys, "sys-root", rdtcat.SysfsPath, ""+
+		"root directory of sysfs used to read the CPU cache hierarchy")
+	flag.StringVar(&cfg.output, "output", "", ""+
+		"file to write the RDT config to, e.g. "+rdtcat.RdtConfigPath+", stdout if empty")
+	flag.IntVar(&cfg.request.CacheLevel, "cache-level", defaultCacheLevel, ""+
+		"cache level of the buffers, 2 or 3")
+	flag.IntVar(&cfg.request.NumBuffers, "buffers", 1, ""+
+		"number of exclusive buffers")
+	flag.IntVar(&cfg.request.SizeKib, "size", 0, ""+
+		"min size of each buffer in KiB")
+	flag.BoolVar(&cfg.debug, "debug", false, "sets log level to debug")
+
+	flag.Parse()
+}
+
+func main() {
+	var cfg config
+
+	parseFlags(&cfg)
+
+	loglevel := zerolog.InfoLevel
+	if cfg.debug {
+		loglevel = zerolog.DebugLevel
+	}
+
+	zerolog.SetGlobalLevel(loglevel)
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	if err := run(cfg); err != nil {
+		log.Fatal().Err(err).Msg("error when planning buffers")
+	}
+}
+
+// run plans the buffers and writes the RDT config.
+func run(cfg config) error {
+	rdtBuffers := rdtcat.NewBuffers(cfg.resctrl)
+	rdtBuffers.SysRoot = cfg.sys
+
+	// planning buffers for a node without classes is fine
+	if err := rdtBuffers.GetAllBuffers(); err != nil && !errors.Is(err, rdtcat.ErrNoClasses) {
+		return fmt.Errorf("error when reading buffers from %v: %w", cfg.resctrl, err)
+	}
+
+	plan, err := rdtBuffers.PlanBuffers(cfg.request)
+	if err != nil {
+		return err
+	}
+
+	for _, buffer := range append([]rdtcat.PlannedBuffer{plan.Default}, plan.Buffers...) {
+		log.Info().Msgf("%v: class %v gets bitmask %#x with %v KiB per cache ID",
+			buffer.Partition, buffer.Class, buffer.Mask, buffer.SizeKib)
+	}
+
+	data, err := plan.RdtConfig()
+	if err != nil {
+		return err
+	}
+
+	if cfg.output == "" {
+		_, err = os.Stdout.Write(data)
+
+		return err
+	}
+
+	if err := os.WriteFile(cfg.output, data, 0o644); err != nil { //nolint:gosec,gomnd // config readable by runtime
+		return fmt.Errorf("error when writing %v: %w", cfg.output, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+#!/usr/bin/env bash
+
+# Copyright (C) 2023 Intel Corporation
+# SPDX-License-Identifier: Apache-2.0
+
+###############################################################################
+# Renders the device plugin of the chart with the default values and with all
+# optional values enabled and checks the arguments, ports and mounts of the
+# device plugin container and the service exposing the inventory.
+# Requires helm and python3 with PyYAML.
+# Arguments:
+# $1: path of the chart, defaults to the directory of this script
+# Returns:
+#   0 if the rendered device plugin is as expected
+###############################################################################
+set -euo pipefail
+
+chart=${1:-$(dirname "$0")}
+
+###############################################################################
+# Function to render the device plugin and check it
+# Arguments:
+# $1: "exposed" if the inventory is exposed to the cluster, else "local"
+# $@: further arguments passed to helm template
+# Returns:
+#   0 if the rendered device plugin is as expected
+###############################################################################
+function check() {
+  local mode=$1
+  shift
+
+  helm template excat "${chart}" "$@" \
+    | python3 -c '
+import sys
+import yaml
+
+mode, set_exclusive = sys.argv[1], sys.argv[2] == "true"
+docs = [doc for doc in yaml.safe_load_all(sys.stdin) if doc]
+kinds = {doc["kind"]: doc for doc in docs if doc["metadata"]["name"].endswith("-deviceplugin")}
+container = kinds["DaemonSet"]["spec"]["template"]["spec"]["containers"][0]
+
+args = container["args"]
+ports = container.get("ports", [])
+mounts = {m["name"]: m for m in container["volumeMounts"]}
+address = "-inventory-address=:9110" if mode == "exposed" else "-inventory-address=127.0.0.1:9110"
+
+errors = []
+if not all(isinstance(arg, str) for arg in args):
+    errors.append("args contain non-string items: %s" % args)
+if address not in args:
+    errors.append("%s missing from args: %s" % (address, args))
+if set_exclusive != ("-set-exclusive" in args):
+    errors.append("unexpected -set-exclusive in args: %s" % args)
+if set_exclusive != (mounts["resctrl"].get("readOnly") is False):
+    errors.append("unexpected resctrl mount: %s" % mounts["resctrl"])
+if mode == "exposed":
+    if ports != [{"name": "inventory", "containerPort": 9110, "protocol": "TCP"}]:
+        errors.append("unexpected ports: %s" % ports)
+    if "Service" not in kinds:
+        errors.append("service exposing the inventory missing")
+else:
+    if ports:
+        errors.append("inventory port exposed: %s" % ports)
+    if "Service" in kinds:
+        errors.append("inventory exposed by a service")
+
+for error in errors:
+    print("%s: %s" % (mode, error), file=sys.stderr)
+sys.exit(1 if errors else 0)
+' "${mode}" "$(printf '%s\n' "$@" | grep -q 'setExclusive=true' && echo true || echo false)"
+}
+
+check local
+check exposed \
+  --set devicePlugin.setExclusive=true \
+  --set devicePlugin.inventory.expose=true
+
+echo "Chart renders as expected."
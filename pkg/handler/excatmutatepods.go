@@ -9,7 +9,9 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/go-logr/zerologr"
 	"github.com/rs/zerolog/log"
@@ -18,6 +20,16 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+const (
+	// mbaAnnotation requests a minimum memory bandwidth for the requested cache
+	mbaAnnotation = "intel.com/excat-mb"
+	// mbaLabelSuffix is the suffix of the node labels advertizing the memory
+	// bandwidth of the buffers of a cache level
+	mbaLabelSuffix = "-mb"
+	// lockedSuffix is the suffix of the resources of pseudo-locked buffers
+	lockedSuffix = "-locked"
+)
+
 // ExcatMutatePods struct for mutating excat pods
 type ExcatMutatePods struct {
 	decoder *admission.Decoder
@@ -70,51 +82,31 @@ func mutateExcatPod(pod *corev1.Pod) error {
 		return nil
 	}
 
-	for annotationKey, annotationValue := range pod.GetAnnotations() {
-		reg := regexp.MustCompile(`^intel\.com/excat-l[2,3]$`)
+	// handle annotations in a fixed order to get reproducible node affinities
+	annotationKeys := make([]string, 0, len(pod.Annotations))
+	for annotationKey := range pod.Annotations {
+		annotationKeys = append(annotationKeys, annotationKey)
+	}
+
+	sort.Strings(annotationKeys)
+
+	var cacheKeys []string
+
+	for _, annotationKey := range annotationKeys {
+		reg := regexp.MustCompile(`^intel\.com/excat-l[2,3](` + lockedSuffix + `)?$`)
 		matched := reg.MatchString(annotationKey)
 
 		if !matched {
 			continue
 		}
 
-		intAnnotationValue, err := strconv.Atoi(annotationValue)
+		intAnnotationValue, err := strconv.Atoi(pod.Annotations[annotationKey])
 		if err != nil {
 			return fmt.Errorf("error converting annotation value to int")
 		}
 
 		// Add affinity to pod
-		if pod.Spec.Affinity == nil {
-			pod.Spec.Affinity = &corev1.Affinity{}
-		}
-
-		if pod.Spec.Affinity.NodeAffinity == nil {
-			pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
-		}
-
-		if pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
-			pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
-		}
-
-		nodeSelectorRequirement := corev1.NodeSelectorRequirement{
-			Key:      annotationKey,
-			Operator: corev1.NodeSelectorOpGt,
-			Values:   []string{strconv.Itoa(intAnnotationValue - 1)},
-		}
-
-		if len(pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms) > 0 {
-			nodeSelectorTerms := pod.Spec.Affinity.NodeAffinity.
-				RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
-			for i := range nodeSelectorTerms {
-				nst := &nodeSelectorTerms[i]
-				nst.MatchExpressions = append(nst.MatchExpressions, nodeSelectorRequirement)
-			}
-		} else {
-			pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.
-				NodeSelectorTerms = []corev1.NodeSelectorTerm{
-				{MatchExpressions: []corev1.NodeSelectorRequirement{nodeSelectorRequirement}},
-			}
-		}
+		addNodeAffinity(pod, annotationKey, intAnnotationValue)
 
 		// Add resource requests and limits to pod
 		for ind := range pod.Spec.Containers {
@@ -151,7 +143,76 @@ func mutateExcatPod(pod *corev1.Pod) error {
 		}
 
 		log.Info().Msg("pod mutated with annotation: " + annotationKey)
+
+		// pseudo-locked buffers are not assigned any tasks, hence no memory
+		// bandwidth
+		if !strings.HasSuffix(annotationKey, lockedSuffix) {
+			cacheKeys = append(cacheKeys, annotationKey)
+		}
 	}
 
+	return mutateMbaPod(pod, cacheKeys)
+}
+
+// mutateMbaPod adds node affinity for the minimum memory bandwidth requested
+// with the MBA annotation. The memory bandwidth is required for the buffers of
+// each cache level requested with the given annotations.
+func mutateMbaPod(pod *corev1.Pod, cacheKeys []string) error {
+	annotationValue, ok := pod.Annotations[mbaAnnotation]
+	if !ok {
+		return nil
+	}
+
+	if len(cacheKeys) == 0 {
+		return fmt.Errorf("annotation %v requires an annotation requesting an ExCAT buffer", mbaAnnotation)
+	}
+
+	intAnnotationValue, err := strconv.Atoi(annotationValue)
+	if err != nil {
+		return fmt.Errorf("error converting annotation value to int")
+	}
+
+	for _, cacheKey := range cacheKeys {
+		addNodeAffinity(pod, cacheKey+mbaLabelSuffix, intAnnotationValue)
+	}
+
+	log.Info().Msg("pod mutated with annotation: " + mbaAnnotation)
+
 	return nil
 }
+
+// addNodeAffinity adds node affinity to a pod requiring the given node label
+// to be at least minValue.
+func addNodeAffinity(pod *corev1.Pod, labelKey string, minValue int) {
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+
+	if pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+
+	nodeSelectorRequirement := corev1.NodeSelectorRequirement{
+		Key:      labelKey,
+		Operator: corev1.NodeSelectorOpGt,
+		Values:   []string{strconv.Itoa(minValue - 1)},
+	}
+
+	if len(pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms) > 0 {
+		nodeSelectorTerms := pod.Spec.Affinity.NodeAffinity.
+			RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		for i := range nodeSelectorTerms {
+			nst := &nodeSelectorTerms[i]
+			nst.MatchExpressions = append(nst.MatchExpressions, nodeSelectorRequirement)
+		}
+	} else {
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.
+			NodeSelectorTerms = []corev1.NodeSelectorTerm{
+			{MatchExpressions: []corev1.NodeSelectorRequirement{nodeSelectorRequirement}},
+		}
+	}
+}
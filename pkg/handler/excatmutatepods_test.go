@@ -64,5 +64,51 @@ var _ = Describe("excat pod mutation", func() {
 				Expect(mutateExcatPod(expectedPod)).Should(Succeed())
 			})
 		})
+		Context("excat pod requesting memory bandwidth", func() {
+			It("should require memory bandwidth for each requested cache level", func() {
+				pod.Annotations[mbaAnnotation] = "50"
+				Expect(mutateExcatPod(pod)).Should(Succeed())
+				terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+				Expect(terms).To(HaveLen(1))
+				Expect(terms[0].MatchExpressions).To(ContainElements(
+					corev1.NodeSelectorRequirement{
+						Key:      "intel.com/excat-l2-mb",
+						Operator: corev1.NodeSelectorOpGt,
+						Values:   []string{"49"},
+					},
+					corev1.NodeSelectorRequirement{
+						Key:      "intel.com/excat-l3-mb",
+						Operator: corev1.NodeSelectorOpGt,
+						Values:   []string{"49"},
+					},
+				))
+				Expect(pod.Spec.Containers[0].Resources).To(Equal(expectedPod.Spec.Containers[0].Resources),
+					"Memory bandwidth should not be requested as resource")
+			})
+		})
+		Context("excat pod requesting a pseudo-locked buffer", func() {
+			It("should require the size of the locked region", func() {
+				pod.Annotations = map[string]string{"intel.com/excat-l2-locked": "256"}
+				Expect(mutateExcatPod(pod)).Should(Succeed())
+				terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+				Expect(terms).To(HaveLen(1))
+				Expect(terms[0].MatchExpressions).To(ConsistOf(corev1.NodeSelectorRequirement{
+					Key:      "intel.com/excat-l2-locked",
+					Operator: corev1.NodeSelectorOpGt,
+					Values:   []string{"255"},
+				}))
+				Expect(pod.Spec.Containers[0].Resources.Limits).To(HaveKey(corev1.ResourceName("intel.com/excat-l2-locked")))
+			})
+			It("should not require memory bandwidth for the locked region", func() {
+				pod.Annotations = map[string]string{"intel.com/excat-l2-locked": "256", mbaAnnotation: "50"}
+				Expect(mutateExcatPod(pod)).ShouldNot(Succeed())
+			})
+		})
+		Context("pod requesting memory bandwidth without cache", func() {
+			It("should return error", func() {
+				pod.Annotations = map[string]string{mbaAnnotation: "50"}
+				Expect(mutateExcatPod(pod)).ShouldNot(Succeed())
+			})
+		})
 	})
 })
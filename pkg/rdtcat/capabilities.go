@@ -0,0 +1,210 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/bits"
+	"path"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Capabilities keeps the RDT CAT capabilities of all utilized cache levels,
+// keyed by cache level ("L2" or "L3").
+type Capabilities map[string]CacheCapabilities
+
+// CacheCapabilities keeps the RDT CAT capabilities of one cache level as
+// reported in /sys/fs/resctrl/info/<cache level> as well as the capacity
+// that is still available for additional exclusive buffers.
+type CacheCapabilities struct {
+	CacheLevel    string
+	NumClosids    int    // max number of classes of service (CLOS)
+	CbmMask       uint64 // capacity bitmask covering the whole cache
+	CbmBits       int    // width of the full capacity bitmask
+	MinCbmBits    int    // min number of consecutive bits to be set in a bitmask
+	ShareableBits uint64 // bits shared with other executing entities, e.g. I/O
+	BitUsage      string // usage of each bit per cache ID, empty if not provided
+	BytesPerBit   int    // cache size allocated by one bit of the bitmask
+	FreeClosids   int    // CLOS not yet used by any class
+	FreeBits      int    // bits neither used by ExCAT buffers nor shareable
+	FreeBuffers   int    // number of exclusive buffers that can still be configured
+}
+
+// Get returns the capabilities of a given cache level.
+func (c Capabilities) Get(cacheLevel int) (CacheCapabilities, bool) {
+	caps, ok := c[fmt.Sprintf("L%v", cacheLevel)]
+
+	return caps, ok
+}
+
+// readCapabilities reads the capabilities of all cache levels utilized by the
+// configured classes and computes the capacity available for additional
+// buffers. Cache levels have to be extracted beforehand.
+func (r *Buffers) readCapabilities() error {
+	r.Capabilities = Capabilities{}
+
+	for _, group := range r.ResctrlGroups {
+		for _, cacheLevel := range group.CacheLevels() {
+			if _, ok := r.Capabilities[cacheLevel]; ok {
+				continue
+			}
+
+			// with CDP, the info of the code and data partition is kept in separate
+			// directories providing the same capabilities
+			infoDir := cacheLevel
+			if group.isCdp(cacheLevel) {
+				infoDir += CdpCode
+			}
+
+			caps, err := r.readCacheCapabilities(cacheLevel, infoDir)
+			if err != nil {
+				return err
+			}
+
+			r.computeFreeCapacity(&caps)
+
+			log.Debug().Msgf("%v capabilities: %+v", caps.CacheLevel, caps)
+
+			r.Capabilities[cacheLevel] = caps
+		}
+	}
+
+	return nil
+}
+
+// readCacheCapabilities reads the info files of one cache level located in
+// the given info directory.
+func (r *Buffers) readCacheCapabilities(cacheLevel string, infoDir string) (CacheCapabilities, error) {
+	caps := CacheCapabilities{CacheLevel: cacheLevel}
+
+	value, err := r.readInfoFile(infoDir, "num_closids")
+	if err != nil {
+		return caps, err
+	}
+
+	if caps.NumClosids, err = strconv.Atoi(value); err != nil {
+		return caps, fmt.Errorf("invalid num_closids for %v: %w", cacheLevel, err)
+	}
+
+	if value, err = r.readInfoFile(infoDir, "cbm_mask"); err != nil {
+		return caps, err
+	}
+
+	if caps.CbmMask, err = parseBitmask(value); err != nil {
+		return caps, fmt.Errorf("invalid cbm_mask for %v: %w", cacheLevel, err)
+	}
+
+	caps.CbmBits = bits.Len64(caps.CbmMask)
+
+	if value, err = r.readInfoFile(infoDir, "min_cbm_bits"); err != nil {
+		return caps, err
+	}
+
+	if caps.MinCbmBits, err = strconv.Atoi(value); err != nil {
+		return caps, fmt.Errorf("invalid min_cbm_bits for %v: %w", cacheLevel, err)
+	}
+
+	if value, err = r.readInfoFile(infoDir, "shareable_bits"); err != nil {
+		return caps, err
+	}
+
+	if caps.ShareableBits, err = parseBitmask(value); err != nil {
+		return caps, fmt.Errorf("invalid shareable_bits for %v: %w", cacheLevel, err)
+	}
+
+	// bit_usage is not provided by older kernels
+	caps.BitUsage, err = r.readInfoFile(infoDir, "bit_usage")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return caps, err
+	}
+
+	return caps, nil
+}
+
+// readInfoFile reads a single line info file of a given info directory.
+func (r *Buffers) readInfoFile(infoDir string, name string) (string, error) {
+	file := path.Join(r.GetRoot(), "info", infoDir, name)
+
+	lines, err := r.ExcatBuffers.ReadFile(file, false)
+	if err != nil {
+		return "", fmt.Errorf("error when reading capabilities: %w", err)
+	}
+
+	if len(lines) != 1 {
+		return "", fmt.Errorf("format error in %v", file)
+	}
+
+	return lines[0], nil
+}
+
+// computeFreeCapacity computes the bytes per bit as well as the CLOS and bits
+// not used by ExCAT buffers of the given cache level. Cache IDs have to be
+// extracted beforehand. Bits used by the default
+// class count as free, since the default class gets the remainder of the cache
+// when ExCAT buffers are added. Though, the default class keeps at least
+// MinCbmBits bits.
+func (r *Buffers) computeFreeCapacity(caps *CacheCapabilities) {
+	var numClasses int
+
+	used := map[int]uint64{}
+
+	for _, group := range r.ResctrlGroups {
+		if !group.HasCacheLevel(caps.CacheLevel) {
+			continue
+		}
+
+		numClasses++
+
+		for _, cacheID := range group.LevelCacheIDs(caps.CacheLevel) {
+			if _, ok := used[cacheID.ID]; !ok {
+				used[cacheID.ID] = caps.ShareableBits
+			}
+
+			if group.Name != DefaultClass {
+				used[cacheID.ID] |= cacheID.Mask
+			}
+
+			// with CDP, the code partition has a size matching its bitmask
+			mask, sizeKib := cacheID.Mask, cacheID.SizeKib
+			if cacheID.CodeMask != 0 {
+				mask, sizeKib = cacheID.CodeMask, cacheID.CodeSizeKib
+			}
+
+			if caps.BytesPerBit == 0 && mask != 0 {
+				caps.BytesPerBit = kib2bytes(sizeKib) / bits.OnesCount64(mask)
+			}
+		}
+	}
+
+	caps.FreeClosids = caps.NumClosids - numClasses
+
+	// the cache ID with the least free bits limits the number of buffers
+	first := true
+
+	for _, usedBits := range used {
+		free := bits.OnesCount64(caps.CbmMask &^ usedBits)
+		if first || free < caps.FreeBits {
+			caps.FreeBits = free
+			first = false
+		}
+	}
+
+	minBits := caps.MinCbmBits
+	if minBits < 1 {
+		minBits = 1
+	}
+
+	caps.FreeBuffers = (caps.FreeBits - minBits) / minBits
+	if caps.FreeBuffers > caps.FreeClosids {
+		caps.FreeBuffers = caps.FreeClosids
+	}
+
+	if caps.FreeBuffers < 0 {
+		caps.FreeBuffers = 0
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChangeKind describes how a class changed between two snapshots.
+type ChangeKind string
+
+const (
+	ChangeAdded       ChangeKind = "added"        // class was added
+	ChangeRemoved     ChangeKind = "removed"      // class was removed
+	ChangeResized     ChangeKind = "resized"      // size of the buffer changed
+	ChangeMaskChanged ChangeKind = "mask-changed" // bitmask on a cache ID changed
+	ChangeModeChanged ChangeKind = "mode-changed" // mode of the class changed
+	ChangeMbaChanged  ChangeKind = "mba-changed"  // memory bandwidth on an MBA domain changed
+)
+
+// Change keeps one change of a class between two snapshots. Sizes refer to
+// the size of the buffer, masks to the bitmask of one cache ID, bandwidths to
+// the throttle value of one MBA domain.
+type Change struct {
+	Kind         ChangeKind
+	Class        string
+	CacheLevel   string
+	CacheID      int    // cache ID of a changed bitmask or ID of a changed MBA domain
+	CdpType      string // CDP type of a changed bitmask, empty for unified
+	OldSizeKib   int
+	NewSizeKib   int
+	OldMask      uint64
+	NewMask      uint64
+	OldMode      string
+	NewMode      string
+	OldBandwidth int
+	NewBandwidth int
+}
+
+// String returns a human readable description of the change.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("class %v was added with %v KiB of %v cache", c.Class, c.NewSizeKib, c.CacheLevel)
+	case ChangeRemoved:
+		return fmt.Sprintf("class %v with %v KiB of %v cache was removed", c.Class, c.OldSizeKib, c.CacheLevel)
+	case ChangeResized:
+		return fmt.Sprintf("class %v was resized from %v to %v KiB", c.Class, c.OldSizeKib, c.NewSizeKib)
+	case ChangeMaskChanged:
+		if c.CdpType != "" {
+			return fmt.Sprintf("%v bitmask of class %v on %v cache ID %v changed from %#x to %#x",
+				c.CdpType, c.Class, c.CacheLevel, c.CacheID, c.OldMask, c.NewMask)
+		}
+
+		return fmt.Sprintf("bitmask of class %v on %v cache ID %v changed from %#x to %#x",
+			c.Class, c.CacheLevel, c.CacheID, c.OldMask, c.NewMask)
+	case ChangeModeChanged:
+		return fmt.Sprintf("mode of class %v changed from %v to %v", c.Class, c.OldMode, c.NewMode)
+	case ChangeMbaChanged:
+		return fmt.Sprintf("memory bandwidth of class %v on MBA domain %v changed from %v to %v",
+			c.Class, c.CacheID, c.OldBandwidth, c.NewBandwidth)
+	}
+
+	return fmt.Sprintf("class %v changed", c.Class)
+}
+
+// Diff compares two snapshots of buffers and returns the changes from the
+// previous to the current snapshot, ordered by class name. A class moving to
+// other cache levels is reported as removed and added. A nil snapshot has no
+// classes.
+func Diff(previous, current *Buffers) []Change {
+	var changes []Change
+
+	oldGroups, newGroups := groupsByName(previous), groupsByName(current)
+
+	names := make([]string, 0, len(oldGroups)+len(newGroups))
+	for name := range oldGroups {
+		names = append(names, name)
+	}
+
+	for name := range newGroups {
+		if _, ok := oldGroups[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldGroup, inOld := oldGroups[name]
+		newGroup, inNew := newGroups[name]
+
+		if inOld && (!inNew || oldGroup.CacheLevel != newGroup.CacheLevel) {
+			changes = append(changes, Change{
+				Kind: ChangeRemoved, Class: name, CacheLevel: oldGroup.CacheLevel, OldSizeKib: oldGroup.SizeKib,
+			})
+		}
+
+		if inNew && (!inOld || oldGroup.CacheLevel != newGroup.CacheLevel) {
+			changes = append(changes, Change{
+				Kind: ChangeAdded, Class: name, CacheLevel: newGroup.CacheLevel, NewSizeKib: newGroup.SizeKib,
+			})
+		}
+
+		if inOld && inNew && oldGroup.CacheLevel == newGroup.CacheLevel {
+			changes = append(changes, diffGroup(oldGroup, newGroup)...)
+		}
+	}
+
+	return changes
+}
+
+// diffGroup returns the size, mode, bitmask and memory bandwidth changes of a
+// class allocating the same cache levels in both snapshots. If either
+// snapshot uses CDP, the code and data bitmasks are compared, a unified
+// bitmask counting for both.
+func diffGroup(previous, current ResctrlGroup) []Change {
+	var changes []Change
+
+	if previous.SizeKib != current.SizeKib {
+		changes = append(changes, Change{
+			Kind: ChangeResized, Class: current.Name, CacheLevel: current.CacheLevel,
+			OldSizeKib: previous.SizeKib, NewSizeKib: current.SizeKib,
+		})
+	}
+
+	if previous.Mode != current.Mode {
+		changes = append(changes, Change{
+			Kind: ChangeModeChanged, Class: current.Name, OldMode: previous.Mode, NewMode: current.Mode,
+		})
+	}
+
+	cdpTypes := []string{""}
+	if previous.Cdp || current.Cdp {
+		cdpTypes = []string{CdpCode, CdpData}
+	}
+
+	masks := func(group ResctrlGroup, cdpType string) map[cacheKey]uint64 {
+		m := make(map[cacheKey]uint64, len(group.CacheIDs))
+		for _, cacheID := range group.CacheIDs {
+			mask := cacheID.Mask
+
+			switch {
+			case !group.Cdp:
+			case cdpType == CdpCode:
+				mask = cacheID.CodeMask
+			case cdpType == CdpData:
+				mask = cacheID.DataMask
+			}
+
+			m[cacheKey{cacheLevel: cacheID.CacheLevel, id: cacheID.ID}] = mask
+		}
+
+		return m
+	}
+
+	for _, cdpType := range cdpTypes {
+		oldMasks, newMasks := masks(previous, cdpType), masks(current, cdpType)
+
+		for _, key := range sortedCacheKeys(oldMasks, newMasks) {
+			if oldMasks[key] != newMasks[key] {
+				changes = append(changes, Change{
+					Kind: ChangeMaskChanged, Class: current.Name, CacheLevel: key.cacheLevel, CacheID: key.id,
+					CdpType: cdpType, OldMask: oldMasks[key], NewMask: newMasks[key],
+				})
+			}
+		}
+	}
+
+	bandwidths := func(group ResctrlGroup) map[cacheKey]int {
+		m := make(map[cacheKey]int, len(group.MbaIDs))
+		for _, mbaID := range group.MbaIDs {
+			m[cacheKey{id: mbaID.ID}] = mbaID.Bandwidth
+		}
+
+		return m
+	}
+
+	oldBandwidths, newBandwidths := bandwidths(previous), bandwidths(current)
+
+	for _, key := range sortedCacheKeys(oldBandwidths, newBandwidths) {
+		if oldBandwidths[key] != newBandwidths[key] {
+			changes = append(changes, Change{
+				Kind: ChangeMbaChanged, Class: current.Name, CacheID: key.id,
+				OldBandwidth: oldBandwidths[key], NewBandwidth: newBandwidths[key],
+			})
+		}
+	}
+
+	return changes
+}
+
+// sortedCacheKeys returns the keys of both maps ordered by cache level and ID.
+func sortedCacheKeys[V any](previous, current map[cacheKey]V) []cacheKey {
+	keys := make([]cacheKey, 0, len(previous)+len(current))
+	for key := range previous {
+		keys = append(keys, key)
+	}
+
+	for key := range current {
+		if _, ok := previous[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].cacheLevel != keys[j].cacheLevel {
+			return keys[i].cacheLevel < keys[j].cacheLevel
+		}
+
+		return keys[i].id < keys[j].id
+	})
+
+	return keys
+}
+
+// groupsByName returns the classes of a snapshot keyed by name.
+func groupsByName(buffers *Buffers) map[string]ResctrlGroup {
+	groups := map[string]ResctrlGroup{}
+	if buffers == nil {
+		return groups
+	}
+
+	for _, group := range buffers.ResctrlGroups {
+		groups[group.Name] = group
+	}
+
+	return groups
+}
@@ -0,0 +1,155 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+	var old *rdtcat.Buffers
+
+	BeforeEach(func() {
+		old = &rdtcat.Buffers{
+			Resctrl: rdtcat.Resctrl{
+				ResctrlGroups: []rdtcat.ResctrlGroup{
+					{Name: "c0", CacheLevel: "L3", SizeKib: 2048, CacheIDs: cacheIDs(0x0f, 0x0f)},
+					{Name: "c1", CacheLevel: "L3", SizeKib: 1024, CacheIDs: cacheIDs(0x30, 0x30)},
+					{Name: "c2", CacheLevel: "L2", SizeKib: 512, CacheIDs: cacheIDs(0x3)},
+				},
+			},
+		}
+	})
+
+	Context("When comparing identical snapshots", func() {
+		It("should not report any changes", func() {
+			Expect(rdtcat.Diff(old, old)).To(BeEmpty())
+		})
+	})
+
+	Context("When classes changed", func() {
+		It("should report typed changes ordered by class", func() {
+			current := &rdtcat.Buffers{
+				Resctrl: rdtcat.Resctrl{
+					ResctrlGroups: []rdtcat.ResctrlGroup{
+						{Name: "c0", CacheLevel: "L3", SizeKib: 1024, CacheIDs: cacheIDs(0x03, 0x0c)},
+						{Name: "c1", CacheLevel: "L3", SizeKib: 1024, CacheIDs: cacheIDs(0x30, 0x30)},
+						{Name: "c3", CacheLevel: "L3", SizeKib: 512, CacheIDs: cacheIDs(0x40, 0x40)},
+					},
+				},
+			}
+
+			changes := rdtcat.Diff(old, current)
+			Expect(changes).To(Equal([]rdtcat.Change{
+				{Kind: rdtcat.ChangeResized, Class: "c0", CacheLevel: "L3", OldSizeKib: 2048, NewSizeKib: 1024},
+				{Kind: rdtcat.ChangeMaskChanged, Class: "c0", CacheID: 0, OldMask: 0x0f, NewMask: 0x03},
+				{Kind: rdtcat.ChangeMaskChanged, Class: "c0", CacheID: 1, OldMask: 0x0f, NewMask: 0x0c},
+				{Kind: rdtcat.ChangeRemoved, Class: "c2", CacheLevel: "L2", OldSizeKib: 512},
+				{Kind: rdtcat.ChangeAdded, Class: "c3", CacheLevel: "L3", NewSizeKib: 512},
+			}))
+			Expect(changes[0].String()).To(Equal("class c0 was resized from 2048 to 1024 KiB"))
+		})
+
+		It("should report a class moving to another cache level as removed and added", func() {
+			current := &rdtcat.Buffers{
+				Resctrl: rdtcat.Resctrl{
+					ResctrlGroups: []rdtcat.ResctrlGroup{
+						old.ResctrlGroups[0], old.ResctrlGroups[1],
+						{Name: "c2", CacheLevel: "L3", SizeKib: 512, CacheIDs: cacheIDs(0x40, 0x40)},
+					},
+				},
+			}
+
+			Expect(rdtcat.Diff(old, current)).To(Equal([]rdtcat.Change{
+				{Kind: rdtcat.ChangeRemoved, Class: "c2", CacheLevel: "L2", OldSizeKib: 512},
+				{Kind: rdtcat.ChangeAdded, Class: "c2", CacheLevel: "L3", NewSizeKib: 512},
+			}))
+		})
+
+		It("should report mode changes", func() {
+			current := &rdtcat.Buffers{
+				Resctrl: rdtcat.Resctrl{
+					ResctrlGroups: []rdtcat.ResctrlGroup{
+						old.ResctrlGroups[0], old.ResctrlGroups[2],
+						{Name: "c1", CacheLevel: "L3", SizeKib: 1024, CacheIDs: cacheIDs(0x30, 0x30), Mode: rdtcat.ModeExclusive},
+					},
+				},
+			}
+			old.ResctrlGroups[1].Mode = rdtcat.ModeShareable
+
+			changes := rdtcat.Diff(old, current)
+			Expect(changes).To(Equal([]rdtcat.Change{
+				{Kind: rdtcat.ChangeModeChanged, Class: "c1", OldMode: rdtcat.ModeShareable, NewMode: rdtcat.ModeExclusive},
+			}))
+			Expect(changes[0].String()).To(Equal("mode of class c1 changed from shareable to exclusive"))
+		})
+
+		It("should report memory bandwidth changes per MBA domain", func() {
+			old.ResctrlGroups[0].MbaIDs = []rdtcat.MbaID{{ID: 0, Bandwidth: 100}, {ID: 1, Bandwidth: 100}}
+			changed := old.ResctrlGroups[0]
+			changed.MbaIDs = []rdtcat.MbaID{{ID: 0, Bandwidth: 100}, {ID: 1, Bandwidth: 50}}
+			current := &rdtcat.Buffers{
+				Resctrl: rdtcat.Resctrl{
+					ResctrlGroups: []rdtcat.ResctrlGroup{changed, old.ResctrlGroups[1], old.ResctrlGroups[2]},
+				},
+			}
+
+			changes := rdtcat.Diff(old, current)
+			Expect(changes).To(Equal([]rdtcat.Change{
+				{Kind: rdtcat.ChangeMbaChanged, Class: "c0", CacheID: 1, OldBandwidth: 100, NewBandwidth: 50},
+			}))
+			Expect(changes[0].String()).To(Equal("memory bandwidth of class c0 on MBA domain 1 changed from 100 to 50"))
+		})
+
+		It("should report code and data bitmask changes with CDP", func() {
+			cdpGroup := func(codeMask, dataMask uint64) rdtcat.ResctrlGroup {
+				return rdtcat.ResctrlGroup{
+					Name: "c1", CacheLevel: "L3", SizeKib: 1024, Cdp: true,
+					CacheIDs: []rdtcat.CacheID{{ID: 0, CacheLevel: "L3", Mask: codeMask | dataMask, CodeMask: codeMask, DataMask: dataMask}},
+				}
+			}
+			old.ResctrlGroups[1] = cdpGroup(0x30, 0x0c)
+			current := &rdtcat.Buffers{
+				Resctrl: rdtcat.Resctrl{
+					ResctrlGroups: []rdtcat.ResctrlGroup{old.ResctrlGroups[0], cdpGroup(0x0c, 0x30), old.ResctrlGroups[2]},
+				},
+			}
+
+			changes := rdtcat.Diff(old, current)
+			Expect(changes).To(Equal([]rdtcat.Change{
+				{Kind: rdtcat.ChangeMaskChanged, Class: "c1", CacheLevel: "L3", CdpType: rdtcat.CdpCode, OldMask: 0x30, NewMask: 0x0c},
+				{Kind: rdtcat.ChangeMaskChanged, Class: "c1", CacheLevel: "L3", CdpType: rdtcat.CdpData, OldMask: 0x0c, NewMask: 0x30},
+			}))
+			Expect(changes[0].String()).To(Equal("CODE bitmask of class c1 on L3 cache ID 0 changed from 0x30 to 0xc"))
+		})
+
+		It("should compare a unified bitmask with the code and data bitmasks", func() {
+			current := &rdtcat.Buffers{
+				Resctrl: rdtcat.Resctrl{
+					ResctrlGroups: []rdtcat.ResctrlGroup{
+						old.ResctrlGroups[0], old.ResctrlGroups[2],
+						{
+							Name: "c1", CacheLevel: "L3", SizeKib: 1024, Cdp: true,
+							CacheIDs: []rdtcat.CacheID{
+								{ID: 0, Mask: 0x30, CodeMask: 0x30, DataMask: 0x30},
+								{ID: 1, Mask: 0x30, CodeMask: 0x10, DataMask: 0x30},
+							},
+						},
+					},
+				},
+			}
+
+			Expect(rdtcat.Diff(old, current)).To(Equal([]rdtcat.Change{
+				{Kind: rdtcat.ChangeMaskChanged, Class: "c1", CacheID: 1, CdpType: rdtcat.CdpCode, OldMask: 0x30, NewMask: 0x10},
+			}))
+		})
+
+		It("should report all classes of a missing snapshot", func() {
+			Expect(rdtcat.Diff(nil, old)).To(HaveLen(3))
+			Expect(rdtcat.Diff(old, nil)).To(HaveEach(HaveField("Kind", rdtcat.ChangeRemoved)))
+		})
+	})
+})
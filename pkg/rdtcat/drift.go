@@ -0,0 +1,590 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/intel/goresctrl/pkg/rdt"
+	"sigs.k8s.io/yaml"
+)
+
+// RdtConfigPath is the default location of the RDT configuration used by
+// containerd and cri-o to set up the classes in the resctrl filesystem.
+const RdtConfigPath = "/etc/rdt-config.yaml"
+
+// DriftKind describes how the resctrl filesystem differs from the RDT
+// configuration.
+type DriftKind string
+
+const (
+	DriftMissingClass    DriftKind = "missing-class"    // class configured but not in resctrl
+	DriftUnexpectedClass DriftKind = "unexpected-class" // class in resctrl but not configured
+	DriftAllocation      DriftKind = "allocation"       // bitmask differs from the configuration
+)
+
+// Drift keeps one difference between the RDT configuration and the resctrl
+// filesystem. Bitmasks refer to one cache ID.
+type Drift struct {
+	Kind       DriftKind
+	Class      string
+	CacheLevel string
+	CacheID    int
+	CdpType    string // CODE or DATA partition, empty without CDP
+	Expected   uint64 // bitmask resulting from the configuration
+	Actual     uint64 // bitmask in the schemata
+}
+
+// String returns a human readable description of the drift.
+func (d Drift) String() string {
+	switch d.Kind {
+	case DriftMissingClass:
+		return fmt.Sprintf("class %v is configured but missing in resctrl", d.Class)
+	case DriftUnexpectedClass:
+		return fmt.Sprintf("class %v in resctrl is not configured", d.Class)
+	}
+
+	return fmt.Sprintf("class %v allocates %#x on %v%v cache ID %v instead of %#x",
+		d.Class, d.Actual, d.CacheLevel, d.CdpType, d.CacheID, d.Expected)
+}
+
+// DriftReport keeps all differences between the RDT configuration and the
+// resctrl filesystem.
+type DriftReport struct {
+	Drifts []Drift
+}
+
+// HasDrift returns true if any difference was detected.
+func (d DriftReport) HasDrift() bool {
+	return len(d.Drifts) > 0
+}
+
+// LoadRdtConfig reads an RDT configuration file as used by containerd and
+// cri-o, e.g. /etc/rdt-config.yaml.
+func LoadRdtConfig(file string) (*rdt.Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading RDT config: %w", err)
+	}
+
+	return ParseRdtConfig(data)
+}
+
+// ParseRdtConfig parses an RDT configuration in YAML format.
+func ParseRdtConfig(data []byte) (*rdt.Config, error) {
+	config := &rdt.Config{}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("error when parsing RDT config: %w", err)
+	}
+
+	return config, nil
+}
+
+// configuredClass keeps a class of the RDT configuration and its partition.
+type configuredClass struct {
+	partition string
+	l2        rdt.CatConfig
+	l3        rdt.CatConfig
+}
+
+// CheckDrift compares the classes of the RDT configuration with the classes
+// read from resctrl. The bitmasks expected on each cache ID are resolved like
+// goresctrl v0.3.0 does when setting up the classes: partitions get exclusive
+// parts of the cache, classes get a part of their partition. goresctrl's own
+// resolution is unexported and reads the capabilities from the mounted
+// resctrl filesystem, so it can't be applied to a snapshot; the tests check
+// the copy against the goresctrl version of go.mod. Configurations goresctrl
+// would resolve differently, e.g. percentage ranges of partitions, are
+// rejected with an error rather than guessed. The default class is only
+// checked if it is configured. Capabilities have to be read beforehand.
+func (r *Buffers) CheckDrift(config *rdt.Config) (DriftReport, error) {
+	var report DriftReport
+
+	classes := map[string]configuredClass{}
+
+	for partitionName, partition := range config.Partitions {
+		for className, class := range partition.Classes {
+			if className == "" {
+				className = DefaultClass
+			}
+
+			classes[className] = configuredClass{
+				partition: partitionName,
+				l2:        class.L2Allocation,
+				l3:        class.L3Allocation,
+			}
+		}
+	}
+
+	groups := groupsByName(r)
+
+	// missing and unexpected classes
+	for _, name := range sortedKeys(classes) {
+		if _, ok := groups[name]; !ok {
+			report.Drifts = append(report.Drifts, Drift{Kind: DriftMissingClass, Class: name})
+		}
+	}
+
+	for _, name := range sortedKeys(groups) {
+		if _, ok := classes[name]; !ok && name != DefaultClass {
+			report.Drifts = append(report.Drifts, Drift{Kind: DriftUnexpectedClass, Class: name})
+		}
+	}
+
+	// allocation drift of configured classes
+	for _, cacheLevel := range sortedKeys(r.Capabilities) {
+		grants, err := resolvePartitions(config, cacheLevel, r.Capabilities[cacheLevel], r.levelCacheIDs(cacheLevel))
+		if err != nil {
+			return report, err
+		}
+
+		for _, name := range sortedKeys(classes) {
+			group, ok := groups[name]
+			if !ok {
+				continue
+			}
+
+			drifts, err := r.checkClassDrift(group, classes[name], cacheLevel, grants[classes[name].partition])
+			if err != nil {
+				return report, err
+			}
+
+			report.Drifts = append(report.Drifts, drifts...)
+		}
+	}
+
+	return report, nil
+}
+
+// checkClassDrift compares the bitmasks of a class on one cache level with
+// the bitmasks resolved from its configuration within the given partition
+// grants.
+func (r *Buffers) checkClassDrift(
+	group ResctrlGroup, class configuredClass, cacheLevel string, grants map[int]map[string]uint64,
+) ([]Drift, error) {
+	var drifts []Drift
+
+	caps := r.Capabilities[cacheLevel]
+
+	classConfig := class.l2
+	if cacheLevel == "L3" {
+		classConfig = class.l3
+	}
+
+	for _, cacheID := range group.LevelCacheIDs(cacheLevel) {
+		actual := map[string]uint64{"": cacheID.Mask}
+		if cacheID.CodeMask != 0 {
+			actual = map[string]uint64{CdpCode: cacheID.CodeMask, CdpData: cacheID.DataMask}
+		}
+
+		for _, cdpType := range sortedKeys(actual) {
+			// the class gets the partition's grant, all of the cache without one
+			baseMask := caps.CbmMask
+			if grant, ok := effectiveGrant(grants[cacheID.ID], cdpType); ok {
+				baseMask = grant
+			}
+
+			expected := baseMask
+
+			if proportion, ok := effectiveProportion(classConfig, cacheID.ID, cdpType); ok {
+				allocation, err := parseCacheProportion(proportion)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %v allocation of class %v: %w", cacheLevel, group.Name, err)
+				}
+
+				if expected, err = allocation.overlay(baseMask, caps.MinCbmBits); err != nil {
+					return nil, fmt.Errorf("invalid %v allocation of class %v: %w", cacheLevel, group.Name, err)
+				}
+			}
+
+			if expected != actual[cdpType] {
+				drifts = append(drifts, Drift{
+					Kind:       DriftAllocation,
+					Class:      group.Name,
+					CacheLevel: cacheLevel,
+					CacheID:    cacheID.ID,
+					CdpType:    cdpType,
+					Expected:   expected,
+					Actual:     actual[cdpType],
+				})
+			}
+		}
+	}
+
+	return drifts, nil
+}
+
+// levelCacheIDs returns the sorted IDs of all cache IDs of a cache level.
+func (r *Buffers) levelCacheIDs(cacheLevel string) []int {
+	var ids []int
+
+	for _, group := range r.ResctrlGroups {
+		for _, cacheID := range group.LevelCacheIDs(cacheLevel) {
+			if !slices.Contains(ids, cacheID.ID) {
+				ids = append(ids, cacheID.ID)
+			}
+		}
+	}
+
+	sort.Ints(ids)
+
+	return ids
+}
+
+// resolvePartitions resolves the bitmasks granted to each partition on each
+// cache ID of a cache level, keyed by partition, cache ID and CDP type (empty
+// for unified). Partitions without allocation for the cache level get no
+// grants.
+func resolvePartitions(
+	config *rdt.Config, cacheLevel string, caps CacheCapabilities, ids []int,
+) (map[string]map[int]map[string]uint64, error) {
+	names := make([]string, 0, len(config.Partitions))
+	for name := range config.Partitions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	grants := make(map[string]map[int]map[string]uint64, len(names))
+	for _, name := range names {
+		grants[name] = map[int]map[string]uint64{}
+	}
+
+	for _, id := range ids {
+		for _, cdpType := range []string{"", CdpCode, CdpData} {
+			requests := make(map[string]*catAllocation, len(names))
+
+			for _, name := range names {
+				partition := config.Partitions[name]
+
+				partitionConfig := partition.L2Allocation
+				if cacheLevel == "L3" {
+					partitionConfig = partition.L3Allocation
+				}
+
+				proportion, ok := proportionOf(partitionConfig, id, cdpType)
+				if !ok {
+					continue
+				}
+
+				allocation, err := parseCacheProportion(proportion)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %v allocation of partition %v: %w", cacheLevel, name, err)
+				}
+
+				requests[name] = allocation
+			}
+
+			masks, err := resolveRequests(names, requests, caps)
+			if err != nil {
+				return nil, fmt.Errorf("error when resolving %v%v partitions of cache ID %v: %w",
+					cacheLevel, cdpType, id, err)
+			}
+
+			for name, mask := range masks {
+				if grants[name][id] == nil {
+					grants[name][id] = map[string]uint64{}
+				}
+
+				grants[name][id][cdpType] = mask
+			}
+		}
+	}
+
+	return grants, nil
+}
+
+// resolveRequests resolves the allocation requests of all partitions on one
+// cache ID. Either all partitions request absolute bitmasks, which are
+// granted as they are, or all partitions request percentages, which are
+// converted to adjacent bitmasks starting with the first partition by name.
+func resolveRequests(names []string, requests map[string]*catAllocation, caps CacheCapabilities) (map[string]uint64, error) {
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	if len(requests) != len(names) {
+		return nil, fmt.Errorf("some partitions are missing an allocation request")
+	}
+
+	masks := make(map[string]uint64, len(names))
+
+	if requests[names[0]].absolute {
+		for _, name := range names {
+			if !requests[name].absolute {
+				return nil, fmt.Errorf("mixing absolute and relative allocations is not supported")
+			}
+
+			masks[name] = requests[name].mask
+		}
+
+		return masks, nil
+	}
+
+	type request struct {
+		name string
+		pct  uint64
+	}
+
+	var percentageTotal uint64
+
+	reqs := make([]request, 0, len(names))
+
+	for _, name := range names {
+		allocation := requests[name]
+		if allocation.absolute {
+			return nil, fmt.Errorf("mixing absolute and relative allocations is not supported")
+		}
+
+		if allocation.lowPct != 0 {
+			return nil, fmt.Errorf("percentage ranges in partition allocations are not supported")
+		}
+
+		percentageTotal += allocation.highPct
+		reqs = append(reqs, request{name: name, pct: allocation.highPct})
+	}
+
+	if percentageTotal > 100 { //nolint:gomnd // percent
+		return nil, fmt.Errorf("partitions request %v%% of the cache", percentageTotal)
+	}
+
+	// smallest requests are resolved first, since they might be rounded up
+	sort.SliceStable(reqs, func(i, j int) bool { return reqs[i].pct < reqs[j].pct })
+
+	minBits := uint64(caps.MinCbmBits)
+	bitsTotal := percentageTotal * uint64(bits.TrailingZeros64(^caps.CbmMask)) / 100 //nolint:gomnd // percent
+	bitsAvailable := bitsTotal
+	numBits := make(map[string]uint64, len(reqs))
+
+	for ind, req := range reqs {
+		if bitsAvailable < minBits || bitsTotal == 0 {
+			return nil, fmt.Errorf("not enough exclusive bits available")
+		}
+
+		percentageAvailable := bitsAvailable * percentageTotal / bitsTotal
+		if percentageAvailable == 0 {
+			return nil, fmt.Errorf("not enough exclusive bits available")
+		}
+
+		// round down to avoid over-allocation, but grant at least minBits
+		granted := req.pct * bitsAvailable / percentageAvailable
+		if granted < minBits {
+			granted = minBits
+		}
+
+		// the last partition gets all remaining bits
+		if granted > bitsAvailable || ind == len(reqs)-1 {
+			granted = bitsAvailable
+		}
+
+		numBits[req.name] = granted
+		bitsAvailable -= granted
+	}
+
+	var lsb uint64
+
+	for _, name := range names {
+		masks[name] = ((1 << numBits[name]) - 1) << lsb
+		lsb += numBits[name]
+	}
+
+	return masks, nil
+}
+
+// effectiveGrant returns the partition grant of a CDP type, falling back to
+// the unified grant.
+func effectiveGrant(grants map[string]uint64, cdpType string) (uint64, bool) {
+	if mask, ok := grants[cdpType]; ok {
+		return mask, true
+	}
+
+	mask, ok := grants[""]
+
+	return mask, ok
+}
+
+// effectiveProportion returns the proportion of a cache ID requested for a
+// CDP type, falling back to the unified proportion.
+func effectiveProportion(config rdt.CatConfig, id int, cdpType string) (rdt.CacheProportion, bool) {
+	if proportion, ok := proportionOf(config, id, cdpType); ok {
+		return proportion, true
+	}
+
+	return proportionOf(config, id, "")
+}
+
+// proportionOf returns the proportion of a cache ID requested for a CDP type
+// (empty for unified). Cache IDs not listed explicitly get the proportion of
+// "all", which defaults to 100% of the unified cache.
+func proportionOf(config rdt.CatConfig, id int, cdpType string) (rdt.CacheProportion, bool) {
+	if config == nil {
+		return "", false
+	}
+
+	idConfig, ok := config[rdt.CacheIdAll]
+	if !ok {
+		idConfig = rdt.CacheIdCatConfig{Unified: "100%"}
+	}
+
+	for key, value := range config {
+		if key == rdt.CacheIdAll {
+			continue
+		}
+
+		if ids, err := parseCPUList(key); err == nil && slices.Contains(ids, id) {
+			idConfig = value
+		}
+	}
+
+	proportion := idConfig.Unified
+
+	switch cdpType {
+	case CdpCode:
+		proportion = idConfig.Code
+	case CdpData:
+		proportion = idConfig.Data
+	}
+
+	return proportion, proportion != ""
+}
+
+// catAllocation keeps a parsed cache proportion of the RDT configuration,
+// either an absolute bitmask or a percentage range.
+type catAllocation struct {
+	absolute bool
+	mask     uint64
+	lowPct   uint64
+	highPct  uint64
+}
+
+// parseCacheProportion parses a cache proportion like "50%", "20-60%",
+// "0xff0" or "4-11".
+func parseCacheProportion(proportion rdt.CacheProportion) (*catAllocation, error) {
+	value := string(proportion)
+
+	if pct, ok := strings.CutSuffix(value, "%"); ok {
+		low, high, isRange := strings.Cut(pct, "-")
+		if !isRange {
+			low, high = "0", low
+		}
+
+		lowPct, err := strconv.ParseUint(low, 10, 7) //nolint:gomnd // at most 100
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentage %q: %w", value, err)
+		}
+
+		highPct, err := strconv.ParseUint(high, 10, 7) //nolint:gomnd // at most 100
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentage %q: %w", value, err)
+		}
+
+		if lowPct > highPct || highPct > 100 {
+			return nil, fmt.Errorf("invalid percentage %q", value)
+		}
+
+		if highPct == 0 {
+			return nil, fmt.Errorf("invalid percentage %q: allocates no cache", value)
+		}
+
+		return &catAllocation{lowPct: lowPct, highPct: highPct}, nil
+	}
+
+	var mask uint64
+
+	if hex, ok := strings.CutPrefix(value, "0x"); ok {
+		var err error
+		if mask, err = parseBitmask(hex); err != nil {
+			return nil, err
+		}
+	} else {
+		bitList, err := parseCPUList(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bit list %q: %w", value, err)
+		}
+
+		for _, bit := range bitList {
+			mask |= 1 << bit
+		}
+	}
+
+	if bits.OnesCount64(mask) != bits.Len64(mask)-bits.TrailingZeros64(mask) {
+		return nil, fmt.Errorf("invalid bitmask %q: more than one contiguous block of ones", value)
+	}
+
+	return &catAllocation{absolute: true, mask: mask}, nil
+}
+
+// overlay returns the bitmask of the allocation within the given base mask.
+// Absolute bitmasks are relative to the lowest bit of the base mask,
+// percentages refer to the width of the base mask.
+func (a catAllocation) overlay(baseMask uint64, minBits int) (uint64, error) {
+	if baseMask == 0 {
+		return 0, fmt.Errorf("empty base mask")
+	}
+
+	baseLsb := uint64(bits.TrailingZeros64(baseMask))
+
+	if a.absolute {
+		mask := a.mask << baseLsb
+		if mask|baseMask != baseMask {
+			return 0, fmt.Errorf("bitmask %#x does not fit base mask %#x", a.mask, baseMask)
+		}
+
+		return mask, nil
+	}
+
+	if a.highPct == 0 {
+		return 0, fmt.Errorf("allocation of 0%% of base mask %#x", baseMask)
+	}
+
+	baseNumBits := uint64(bits.Len64(baseMask)) - baseLsb
+
+	low := a.lowPct
+	if low == 0 {
+		low = 1
+	}
+
+	lsb := (low - 1) * baseNumBits / 100       //nolint:gomnd // percent
+	msb := (a.highPct - 1) * baseNumBits / 100 //nolint:gomnd // percent
+
+	// widen the bitmask to minBits, first towards the lowest bit
+	if numBits := msb - lsb + 1; numBits < uint64(minBits) {
+		gap := uint64(minBits) - numBits
+
+		if gap <= lsb {
+			lsb -= gap
+			gap = 0
+		} else {
+			gap -= lsb
+			lsb = 0
+		}
+
+		if gap > baseNumBits-msb-1 {
+			return 0, fmt.Errorf("base mask %#x has less than %v bits", baseMask, minBits)
+		}
+
+		msb += gap
+	}
+
+	return ((1 << (msb - lsb + 1)) - 1) << (lsb + baseLsb), nil
+}
+
+// sortedKeys returns the sorted keys of a map.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,184 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"testing/fstest"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const rdtConfig = `
+partitions:
+  pdef:
+    l3Allocation: "50%"
+    classes:
+      system/default:
+        l3Allocation: "100%"
+  p0:
+    l3Allocation: "25%"
+    classes:
+      c0:
+        l3Allocation: "100%"
+  p1:
+    l3Allocation: "25%"
+    classes:
+      c1:
+        l3Allocation: "0x3"
+      c2:
+        l3Allocation: "100%"
+`
+
+var _ = Describe("Drift", func() {
+	var (
+		rdtcatBuffers *rdtcat.Buffers
+		resctrlFS     fstest.MapFS
+	)
+
+	BeforeEach(func() {
+		resctrlFS = fstest.MapFS{
+			"schemata":               {Data: []byte("L3:0=ffc00;1=ffc00\n")},
+			"size":                   {Data: []byte("L3:0=13107200;1=13107200\n")},
+			"tasks":                  {Data: []byte("1\n")},
+			"c0/schemata":            {Data: []byte("L3:0=1f;1=1f\n")},
+			"c0/size":                {Data: []byte("L3:0=6553600;1=6553600\n")},
+			"c0/tasks":               {Data: []byte("")},
+			"c1/schemata":            {Data: []byte("L3:0=60;1=60\n")},
+			"c1/size":                {Data: []byte("L3:0=2621440;1=2621440\n")},
+			"c1/tasks":               {Data: []byte("")},
+			"c2/schemata":            {Data: []byte("L3:0=3e0;1=3e0\n")},
+			"c2/size":                {Data: []byte("L3:0=6553600;1=6553600\n")},
+			"c2/tasks":               {Data: []byte("")},
+			"info/L3/num_closids":    {Data: []byte("16\n")},
+			"info/L3/cbm_mask":       {Data: []byte("fffff\n")},
+			"info/L3/min_cbm_bits":   {Data: []byte("1\n")},
+			"info/L3/shareable_bits": {Data: []byte("0\n")},
+		}
+	})
+
+	JustBeforeEach(func() {
+		rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", resctrlFS)
+		rdtcatBuffers.SysFS = fstest.MapFS{}
+		Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+	})
+
+	Context("When resctrl matches the RDT config", func() {
+		It("should not report any drift", func() {
+			config, err := rdtcat.ParseRdtConfig([]byte(rdtConfig))
+			Expect(err).NotTo(HaveOccurred())
+
+			report, err := rdtcatBuffers.CheckDrift(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.HasDrift()).To(BeFalse(), "%v", report.Drifts)
+		})
+	})
+
+	Context("When resctrl differs from the RDT config", func() {
+		BeforeEach(func() {
+			resctrlFS["c0/schemata"] = &fstest.MapFile{Data: []byte("L3:0=1f;1=0f\n")}
+			resctrlFS["c3/schemata"] = &fstest.MapFile{Data: []byte("L3:0=1;1=1\n")}
+			resctrlFS["c3/size"] = &fstest.MapFile{Data: []byte("L3:0=1310720;1=1310720\n")}
+			resctrlFS["c3/tasks"] = &fstest.MapFile{Data: []byte("")}
+			delete(resctrlFS, "c2/schemata")
+			delete(resctrlFS, "c2/size")
+			delete(resctrlFS, "c2/tasks")
+		})
+
+		It("should report missing and unexpected classes and allocation drift", func() {
+			config, err := rdtcat.ParseRdtConfig([]byte(rdtConfig))
+			Expect(err).NotTo(HaveOccurred())
+
+			report, err := rdtcatBuffers.CheckDrift(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Drifts).To(Equal([]rdtcat.Drift{
+				{Kind: rdtcat.DriftMissingClass, Class: "c2"},
+				{Kind: rdtcat.DriftUnexpectedClass, Class: "c3"},
+				{Kind: rdtcat.DriftAllocation, Class: "c0", CacheLevel: "L3", CacheID: 1, Expected: 0x1f, Actual: 0x0f},
+			}))
+		})
+	})
+
+	Context("When the RDT config is invalid", func() {
+		It("should return an error", func() {
+			config, err := rdtcat.ParseRdtConfig([]byte(`
+partitions:
+  p0:
+    l3Allocation: "60%"
+  p1:
+    l3Allocation: "60%"
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = rdtcatBuffers.CheckDrift(config)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("When an allocation requests 0% of the cache", func() {
+		It("should return an error for partitions", func() {
+			config, err := rdtcat.ParseRdtConfig([]byte(`
+partitions:
+  p0:
+    l3Allocation: "0%"
+    classes:
+      c0:
+        l3Allocation: "100%"
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = rdtcatBuffers.CheckDrift(config)
+			Expect(err).To(MatchError(ContainSubstring("allocates no cache")))
+		})
+
+		It("should return an error for classes", func() {
+			config, err := rdtcat.ParseRdtConfig([]byte(`
+partitions:
+  p0:
+    l3Allocation: "100%"
+    classes:
+      c0:
+        l3Allocation: "0-0%"
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = rdtcatBuffers.CheckDrift(config)
+			Expect(err).To(MatchError(ContainSubstring("allocates no cache")))
+		})
+	})
+
+	Context("When the partitions get less than one bit of the cache", func() {
+		BeforeEach(func() {
+			resctrlFS["schemata"] = &fstest.MapFile{Data: []byte("L3:0=f;1=f\n")}
+			resctrlFS["size"] = &fstest.MapFile{Data: []byte("L3:0=13107200;1=13107200\n")}
+			resctrlFS["info/L3/cbm_mask"] = &fstest.MapFile{Data: []byte("f\n")}
+
+			for _, class := range []string{"c0", "c1", "c2"} {
+				resctrlFS[class+"/schemata"] = &fstest.MapFile{Data: []byte("L3:0=1;1=1\n")}
+				resctrlFS[class+"/size"] = &fstest.MapFile{Data: []byte("L3:0=3276800;1=3276800\n")}
+			}
+		})
+
+		It("should return an error", func() {
+			config, err := rdtcat.ParseRdtConfig([]byte(`
+partitions:
+  p0:
+    l3Allocation: "10%"
+    classes:
+      c0:
+        l3Allocation: "100%"
+  p1:
+    l3Allocation: "10%"
+    classes:
+      c1:
+        l3Allocation: "100%"
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = rdtcatBuffers.CheckDrift(config)
+			Expect(err).To(MatchError(ContainSubstring("not enough exclusive bits available")))
+		})
+	})
+})
@@ -0,0 +1,29 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import "errors"
+
+// Errors returned by rdtcat. They are wrapped with details and can be checked
+// with errors.Is.
+var (
+	// ErrRDTUnsupported is returned if no resctrl filesystem is available.
+	ErrRDTUnsupported = errors.New("RDT not supported")
+	// ErrNoClasses is returned if no classes are configured for ExCAT.
+	ErrNoClasses = errors.New("no classes configured")
+	// ErrSchemataFormat is returned for malformed schemata or size files.
+	ErrSchemataFormat = errors.New("format error in schemata")
+	// ErrDuplicateCacheLevel is returned if a class defines a cache level
+	// several times or mixes unified and CDP definitions of a cache level.
+	ErrDuplicateCacheLevel = errors.New("cache level defined several times")
+	// ErrMonitoringEvent is returned if the kernel failed to read a
+	// monitoring event.
+	ErrMonitoringEvent = errors.New("monitoring event failed")
+	// ErrReadOnly is returned when writing to a resctrl tree that is read
+	// through a filesystem other than the local resctrl root, e.g. a snapshot.
+	ErrReadOnly = errors.New("resctrl tree is read-only")
+	// ErrInsufficientCapacity is returned if a requested buffer layout does
+	// not fit into the cache or the available CLOS.
+	ErrInsufficientCapacity = errors.New("insufficient cache capacity")
+)
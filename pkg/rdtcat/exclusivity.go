@@ -0,0 +1,166 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OverlapKind describes what the capacity bitmask of an ExCAT class overlaps with.
+type OverlapKind string
+
+const (
+	OverlapClass     OverlapKind = "class"     // bitmask of another ExCAT class
+	OverlapDefault   OverlapKind = "default"   // bitmask of the default class
+	OverlapShareable OverlapKind = "shareable" // shareable bits of the cache level
+)
+
+// Overlap keeps one overlap of capacity bitmasks on one cache ID.
+type Overlap struct {
+	Kind       OverlapKind
+	Class      string // ExCAT class
+	Other      string // class the bits are shared with, empty for shareable bits
+	CacheLevel string
+	CacheID    int
+	Bits       uint64 // overlapping bits
+}
+
+// String returns a human readable description of the overlap.
+func (o Overlap) String() string {
+	if o.Kind == OverlapShareable {
+		return fmt.Sprintf("class %v shares bits %#x of %v cache ID %v with other executing entities",
+			o.Class, o.Bits, o.CacheLevel, o.CacheID)
+	}
+
+	return fmt.Sprintf("class %v shares bits %#x of %v cache ID %v with class %v",
+		o.Class, o.Bits, o.CacheLevel, o.CacheID, o.Other)
+}
+
+// ExclusivityReport keeps all overlaps detected for ExCAT classes.
+type ExclusivityReport struct {
+	Overlaps []Overlap
+}
+
+// IsExclusive returns true if no overlaps were detected.
+func (e ExclusivityReport) IsExclusive() bool {
+	return len(e.Overlaps) == 0
+}
+
+// IsShared returns true if the given class is involved in any overlap.
+func (e ExclusivityReport) IsShared(class string) bool {
+	for _, overlap := range e.Overlaps {
+		if overlap.Class == class || overlap.Other == class {
+			return true
+		}
+	}
+
+	return false
+}
+
+// classMasks keeps the capacity bitmasks of one class per cache ID.
+type classMasks struct {
+	name       string
+	cacheLevel string
+	masks      map[int]uint64
+}
+
+// CheckExclusivity checks the capacity bitmasks of all ExCAT classes for
+// overlaps with other ExCAT classes, the default class and the shareable bits
+// of the respective cache level. Only bitmasks on the same cache level and
+// cache ID are compared, classes allocating several cache levels are checked
+// on each of them. With CDP, the bitmasks of the code and data partition
+// are combined. Shareable bits are checked if capabilities were read.
+func (r *Buffers) CheckExclusivity() ExclusivityReport {
+	var (
+		report         ExclusivityReport
+		classes        []classMasks
+		defaultClasses = map[string]classMasks{} // keyed by cache level
+	)
+
+	for _, group := range r.ResctrlGroups {
+		for _, cacheLevel := range group.CacheLevels() {
+			cacheIDs := group.LevelCacheIDs(cacheLevel)
+			class := classMasks{
+				name:       group.Name,
+				cacheLevel: cacheLevel,
+				masks:      make(map[int]uint64, len(cacheIDs)),
+			}
+
+			for _, cacheID := range cacheIDs {
+				class.masks[cacheID.ID] = cacheID.Mask
+			}
+
+			if group.Name == DefaultClass {
+				defaultClasses[cacheLevel] = class
+
+				continue
+			}
+
+			classes = append(classes, class)
+		}
+	}
+
+	for ind, class := range classes {
+		// shareable bits
+		if caps, ok := r.Capabilities[class.cacheLevel]; ok && caps.ShareableBits != 0 {
+			report.Overlaps = append(report.Overlaps,
+				class.overlaps(OverlapShareable, "", sharedMasks(class, caps.ShareableBits))...)
+		}
+
+		// default class
+		if defaultClass, ok := defaultClasses[class.cacheLevel]; ok {
+			report.Overlaps = append(report.Overlaps,
+				class.overlaps(OverlapDefault, defaultClass.name, defaultClass.masks)...)
+		}
+
+		// other ExCAT classes, each pair is only checked once
+		for _, other := range classes[ind+1:] {
+			if other.cacheLevel == class.cacheLevel {
+				report.Overlaps = append(report.Overlaps,
+					class.overlaps(OverlapClass, other.name, other.masks)...)
+			}
+		}
+	}
+
+	return report
+}
+
+// overlaps returns the overlaps of the class' bitmasks with the given
+// bitmasks on each cache ID.
+func (c classMasks) overlaps(kind OverlapKind, other string, masks map[int]uint64) []Overlap {
+	var overlaps []Overlap
+
+	ids := make([]int, 0, len(c.masks))
+	for id := range c.masks {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if shared := c.masks[id] & masks[id]; shared != 0 {
+			overlaps = append(overlaps, Overlap{
+				Kind:       kind,
+				Class:      c.name,
+				Other:      other,
+				CacheLevel: c.cacheLevel,
+				CacheID:    id,
+				Bits:       shared,
+			})
+		}
+	}
+
+	return overlaps
+}
+
+// sharedMasks returns the given bitmask for each cache ID of the class.
+func sharedMasks(c classMasks, bitmask uint64) map[int]uint64 {
+	masks := make(map[int]uint64, len(c.masks))
+	for id := range c.masks {
+		masks[id] = bitmask
+	}
+
+	return masks
+}
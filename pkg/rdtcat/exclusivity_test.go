@@ -0,0 +1,74 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exclusivity", func() {
+	var rdtcatBuffers *rdtcat.Buffers
+
+	Context("When reading a snapshot with exclusive buffers", func() {
+		It("should not report any overlap", func() {
+			rdtcatBuffers = rdtcat.NewBuffers(snapshotRoot)
+			rdtcatBuffers.SysRoot = snapshotSysRoot
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+
+			Expect(rdtcatBuffers.CheckExclusivity().IsExclusive()).To(BeTrue())
+		})
+	})
+
+	Context("When bitmasks of classes overlap", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = &rdtcat.Buffers{
+				Resctrl: rdtcat.Resctrl{
+					ResctrlGroups: []rdtcat.ResctrlGroup{
+						{Name: "class0", CacheLevel: "L3", CacheIDs: cacheIDs(0x00003, 0x00003)},
+						{Name: "class1", CacheLevel: "L3", CacheIDs: cacheIDs(0x0001c, 0x00006)},
+						{Name: "class2", CacheLevel: "L3", CacheIDs: cacheIDs(0x30000, 0x00020)},
+						{Name: "class3", CacheLevel: "L2", CacheIDs: cacheIDs(0x3, 0x3)},
+						{Name: rdtcat.DefaultClass, CacheLevel: "L3", CacheIDs: cacheIDs(0xf0000, 0xf0000)},
+					},
+				},
+				Capabilities: rdtcat.Capabilities{
+					"L3": {CacheLevel: "L3", ShareableBits: 0x00020},
+				},
+			}
+		})
+
+		It("should report overlaps with other classes, the default class and shareable bits", func() {
+			report := rdtcatBuffers.CheckExclusivity()
+			Expect(report.IsExclusive()).To(BeFalse())
+			Expect(report.Overlaps).To(ConsistOf(
+				rdtcat.Overlap{
+					Kind: rdtcat.OverlapClass, Class: "class0", Other: "class1",
+					CacheLevel: "L3", CacheID: 1, Bits: 0x2,
+				},
+				rdtcat.Overlap{
+					Kind: rdtcat.OverlapDefault, Class: "class2", Other: rdtcat.DefaultClass,
+					CacheLevel: "L3", CacheID: 0, Bits: 0x30000,
+				},
+				rdtcat.Overlap{
+					Kind: rdtcat.OverlapShareable, Class: "class2",
+					CacheLevel: "L3", CacheID: 1, Bits: 0x20,
+				},
+			))
+			Expect(report.IsShared("class0")).To(BeTrue())
+			Expect(report.IsShared("class3")).To(BeFalse())
+		})
+	})
+})
+
+// cacheIDs returns cache IDs 0, 1, ... with the given bitmasks.
+func cacheIDs(masks ...uint64) []rdtcat.CacheID {
+	ids := make([]rdtcat.CacheID, len(masks))
+	for id, mask := range masks {
+		ids[id] = rdtcat.CacheID{ID: id, Mask: mask}
+	}
+
+	return ids
+}
@@ -0,0 +1,239 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"testing/fstest"
+	_ "unsafe" // for go:linkname
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	grclog "github.com/intel/goresctrl/pkg/log"
+	"github.com/intel/goresctrl/pkg/rdt"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// goresctrl finds the resctrl filesystem in the mount table only, point it to
+// a fake one to let it set up the classes in a temporary directory.
+//
+//go:linkname goresctrlMountInfoPath github.com/intel/goresctrl/pkg/rdt.mountInfoPath
+var goresctrlMountInfoPath string
+
+// The bitmasks CheckDrift expects are resolved by a copy of goresctrl's
+// unexported resolution. These tests set up the classes with the goresctrl
+// version of go.mod and expect no drift, so any difference in the resolution
+// shows up when goresctrl is updated.
+var _ = Describe("Drift against goresctrl", func() {
+	var (
+		root         string
+		mountInfo    string
+		resctrlFiles map[string]string
+	)
+
+	BeforeEach(func() {
+		resctrlFiles = map[string]string{
+			"schemata":               "L2:0=ff;1=ff\nL3:0=fffff;1=fffff\n",
+			"tasks":                  "1\n",
+			"info/L2/num_closids":    "8\n",
+			"info/L2/cbm_mask":       "ff\n",
+			"info/L2/min_cbm_bits":   "1\n",
+			"info/L2/shareable_bits": "0\n",
+			"info/L3/num_closids":    "16\n",
+			"info/L3/cbm_mask":       "fffff\n",
+			"info/L3/min_cbm_bits":   "2\n",
+			"info/L3/shareable_bits": "0\n",
+		}
+
+		previous := goresctrlMountInfoPath
+		DeferCleanup(func() { goresctrlMountInfoPath = previous })
+
+		rdt.SetLogger(grclog.NewLoggerWrapper(stdlog.New(io.Discard, "", 0)))
+	})
+
+	JustBeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		root = filepath.Join(dir, "resctrl")
+		for name, data := range resctrlFiles {
+			Expect(os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(root, name), []byte(data), 0o644)).To(Succeed())
+		}
+
+		mountInfo = filepath.Join(dir, "mounts")
+		Expect(os.WriteFile(mountInfo, []byte("resctrl "+root+" resctrl rw 0 0\n"), 0o644)).To(Succeed())
+	})
+
+	// setUp lets goresctrl set up the classes of the configuration and reads
+	// them back like the kernel would present them.
+	setUp := func(config *rdt.Config) *rdtcat.Buffers {
+		goresctrlMountInfoPath = mountInfo
+		Expect(rdt.Initialize("")).To(Succeed())
+		Expect(rdt.SetConfig(config, true)).To(Succeed())
+
+		// the kernel creates the tasks file of new classes
+		entries, err := os.ReadDir(root)
+		Expect(err).NotTo(HaveOccurred())
+		for _, entry := range entries {
+			if entry.IsDir() && entry.Name() != "info" {
+				Expect(os.WriteFile(filepath.Join(root, entry.Name(), "tasks"), nil, 0o644)).To(Succeed())
+			}
+		}
+
+		buffers := rdtcat.NewBuffersFS(root, os.DirFS(root))
+		buffers.SysFS = fstest.MapFS{
+			"devices/system/cpu/cpu0/cache/index2/level":           {Data: []byte("2\n")},
+			"devices/system/cpu/cpu0/cache/index2/id":              {Data: []byte("0\n")},
+			"devices/system/cpu/cpu0/cache/index2/size":            {Data: []byte("1024K\n")},
+			"devices/system/cpu/cpu0/cache/index2/shared_cpu_list": {Data: []byte("0\n")},
+			"devices/system/cpu/cpu0/cache/index3/level":           {Data: []byte("3\n")},
+			"devices/system/cpu/cpu0/cache/index3/id":              {Data: []byte("0\n")},
+			"devices/system/cpu/cpu0/cache/index3/size":            {Data: []byte("20480K\n")},
+			"devices/system/cpu/cpu0/cache/index3/shared_cpu_list": {Data: []byte("0\n")},
+			"devices/system/cpu/cpu1/cache/index2/level":           {Data: []byte("2\n")},
+			"devices/system/cpu/cpu1/cache/index2/id":              {Data: []byte("1\n")},
+			"devices/system/cpu/cpu1/cache/index2/size":            {Data: []byte("1024K\n")},
+			"devices/system/cpu/cpu1/cache/index2/shared_cpu_list": {Data: []byte("1\n")},
+			"devices/system/cpu/cpu1/cache/index3/level":           {Data: []byte("3\n")},
+			"devices/system/cpu/cpu1/cache/index3/id":              {Data: []byte("1\n")},
+			"devices/system/cpu/cpu1/cache/index3/size":            {Data: []byte("20480K\n")},
+			"devices/system/cpu/cpu1/cache/index3/shared_cpu_list": {Data: []byte("1\n")},
+		}
+		Expect(buffers.GetAllBuffers()).To(Succeed())
+		return buffers
+	}
+
+	DescribeTable("resolves the bitmasks like goresctrl",
+		func(data string) {
+			config, err := rdtcat.ParseRdtConfig([]byte(data))
+			Expect(err).NotTo(HaveOccurred())
+
+			buffers := setUp(config)
+
+			report, err := buffers.CheckDrift(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.HasDrift()).To(BeFalse(), "%v", report.Drifts)
+		},
+		Entry("with percentages", rdtConfig),
+		Entry("with percentage ranges of classes", `
+partitions:
+  p0:
+    l3Allocation: "60%"
+    classes:
+      c0:
+        l3Allocation: "0-50%"
+      c1:
+        l3Allocation: "50-100%"
+  p1:
+    l3Allocation: "40%"
+    classes:
+      c2:
+        l3Allocation: "25%"
+`),
+		Entry("with bitmasks", `
+partitions:
+  p0:
+    l3Allocation: "0xff"
+    classes:
+      c0:
+        l3Allocation: "0xf0"
+      c1:
+        l3Allocation: "0-3"
+  p1:
+    l3Allocation: "0xfff00"
+    classes:
+      c2:
+        l3Allocation: "0x3"
+`),
+		Entry("with cache IDs configured separately", `
+partitions:
+  p0:
+    l3Allocation:
+      all: "50%"
+      1: "30%"
+    classes:
+      c0:
+        l3Allocation:
+          all: "100%"
+          1: "50%"
+  p1:
+    l3Allocation:
+      all: "50%"
+      1: "70%"
+    classes:
+      c1:
+        l3Allocation: "60%"
+`),
+		Entry("with L2 and L3", `
+partitions:
+  p0:
+    l2Allocation: "50%"
+    l3Allocation: "75%"
+    classes:
+      c0:
+        l2Allocation: "100%"
+        l3Allocation: "50%"
+  p1:
+    l2Allocation: "50%"
+    l3Allocation: "25%"
+    classes:
+      c1:
+        l2Allocation: "0x3"
+        l3Allocation: "100%"
+`),
+	)
+
+	Context("When CDP is enabled", func() {
+		BeforeEach(func() {
+			resctrlFiles["schemata"] = "L3CODE:0=fffff;1=fffff\nL3DATA:0=fffff;1=fffff\n"
+			for _, cdpType := range []string{"CODE", "DATA"} {
+				for _, file := range []string{"num_closids", "cbm_mask", "min_cbm_bits", "shareable_bits"} {
+					resctrlFiles["info/L3"+cdpType+"/"+file] = resctrlFiles["info/L3/"+file]
+				}
+			}
+			for name := range resctrlFiles {
+				if filepath.Dir(name) == "info/L2" || filepath.Dir(name) == "info/L3" {
+					delete(resctrlFiles, name)
+				}
+			}
+		})
+
+		It("resolves code and data bitmasks like goresctrl", func() {
+			config, err := rdtcat.ParseRdtConfig([]byte(`
+partitions:
+  p0:
+    l3Allocation:
+      all:
+        unified: "50%"
+        code: "40%"
+        data: "60%"
+    classes:
+      c0:
+        l3Allocation:
+          all:
+            unified: "100%"
+            code: "50%"
+            data: "100%"
+  p1:
+    l3Allocation:
+      all:
+        unified: "50%"
+        code: "60%"
+        data: "40%"
+    classes:
+      c1:
+        l3Allocation: "100%"
+`))
+			Expect(err).NotTo(HaveOccurred())
+
+			buffers := setUp(config)
+
+			report, err := buffers.CheckDrift(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.HasDrift()).To(BeFalse(), "%v", report.Drifts)
+		})
+	})
+})
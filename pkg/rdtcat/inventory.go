@@ -0,0 +1,210 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Version and kind of the inventory format. Fields are only added within a
+// version, renamed or removed fields require a new version.
+const (
+	InventoryVersion = "excat.intel.com/v1"
+	InventoryKind    = "Inventory"
+)
+
+// InventoryPath is the default location the device plugin writes the
+// inventory of the node to.
+const InventoryPath = "/run/excat/inventory.json"
+
+// Inventory keeps the buffers of a node in a stable format for node agents
+// and support bundles. Masks are hexadecimal strings. Labels are keyed by
+// label name and have to be set by the caller, e.g. the device plugin.
+type Inventory struct {
+	APIVersion     string                  `json:"apiVersion"`
+	Kind           string                  `json:"kind"`
+	Root           string                  `json:"root"`
+	Classes        []InventoryClass        `json:"classes"`
+	Capabilities   []InventoryCapabilities `json:"capabilities"`
+	Labels         map[string]string       `json:"labels,omitempty"`
+	SizeMismatches []InventorySizeMismatch `json:"sizeMismatches,omitempty"`
+}
+
+// InventoryClass keeps one class of the inventory.
+type InventoryClass struct {
+	Name        string             `json:"name"`
+	Mode        string             `json:"mode,omitempty"`
+	CacheLevel  string             `json:"cacheLevel"`
+	SizeKib     int                `json:"sizeKib"`
+	Cdp         bool               `json:"cdp,omitempty"`
+	SizeDerived bool               `json:"sizeDerived,omitempty"`
+	CacheIDs    []InventoryCacheID `json:"cacheIds"`
+	MbaIDs      []InventoryMbaID   `json:"mbaIds,omitempty"`
+}
+
+// InventoryCacheID keeps the allocation of a class on one cache ID.
+type InventoryCacheID struct {
+	ID          int    `json:"id"`
+	CacheLevel  string `json:"cacheLevel"`
+	Mask        string `json:"mask"`
+	SizeKib     int    `json:"sizeKib"`
+	CodeMask    string `json:"codeMask,omitempty"`
+	CodeSizeKib int    `json:"codeSizeKib,omitempty"`
+	DataMask    string `json:"dataMask,omitempty"`
+	DataSizeKib int    `json:"dataSizeKib,omitempty"`
+	SharedCPUs  []int  `json:"sharedCpus,omitempty"`
+	NumaNodes   []int  `json:"numaNodes,omitempty"`
+}
+
+// InventoryMbaID keeps the memory bandwidth of a class on one MBA domain.
+type InventoryMbaID struct {
+	ID        int `json:"id"`
+	Bandwidth int `json:"bandwidth"`
+}
+
+// InventoryCapabilities keeps the capabilities of one cache level.
+type InventoryCapabilities struct {
+	CacheLevel    string `json:"cacheLevel"`
+	NumClosids    int    `json:"numClosids"`
+	CbmMask       string `json:"cbmMask"`
+	MinCbmBits    int    `json:"minCbmBits"`
+	ShareableBits string `json:"shareableBits"`
+	BytesPerBit   int    `json:"bytesPerBit"`
+	FreeClosids   int    `json:"freeClosids"`
+	FreeBits      int    `json:"freeBits"`
+	FreeBuffers   int    `json:"freeBuffers"`
+}
+
+// InventorySizeMismatch keeps a difference between the reported and the
+// derived size of a class on one cache ID.
+type InventorySizeMismatch struct {
+	Class          string `json:"class"`
+	CacheLevel     string `json:"cacheLevel"`
+	CacheID        int    `json:"cacheId"`
+	CdpType        string `json:"cdpType,omitempty"`
+	SizeKib        int    `json:"sizeKib"`
+	DerivedSizeKib int    `json:"derivedSizeKib"`
+}
+
+// Inventory returns the inventory of the buffers. Capabilities are sorted by
+// cache level, classes keep the order read from resctrl.
+func (r *Buffers) Inventory() *Inventory {
+	inventory := &Inventory{
+		APIVersion:   InventoryVersion,
+		Kind:         InventoryKind,
+		Root:         r.GetRoot(),
+		Classes:      make([]InventoryClass, 0, len(r.ResctrlGroups)),
+		Capabilities: make([]InventoryCapabilities, 0, len(r.Capabilities)),
+	}
+
+	for _, group := range r.ResctrlGroups {
+		class := InventoryClass{
+			Name:        group.Name,
+			Mode:        group.Mode,
+			CacheLevel:  group.CacheLevel,
+			SizeKib:     group.SizeKib,
+			Cdp:         group.Cdp,
+			SizeDerived: group.SizeDerived,
+			CacheIDs:    make([]InventoryCacheID, 0, len(group.CacheIDs)),
+		}
+
+		for _, cacheID := range group.CacheIDs {
+			class.CacheIDs = append(class.CacheIDs, InventoryCacheID{
+				ID:          cacheID.ID,
+				CacheLevel:  cacheID.CacheLevel,
+				Mask:        hexMask(cacheID.Mask),
+				SizeKib:     cacheID.SizeKib,
+				CodeMask:    hexMask(cacheID.CodeMask),
+				CodeSizeKib: cacheID.CodeSizeKib,
+				DataMask:    hexMask(cacheID.DataMask),
+				DataSizeKib: cacheID.DataSizeKib,
+				SharedCPUs:  cacheID.SharedCPUs,
+				NumaNodes:   cacheID.NumaNodes,
+			})
+		}
+
+		for _, mbaID := range group.MbaIDs {
+			class.MbaIDs = append(class.MbaIDs, InventoryMbaID{ID: mbaID.ID, Bandwidth: mbaID.Bandwidth})
+		}
+
+		inventory.Classes = append(inventory.Classes, class)
+	}
+
+	levels := make([]string, 0, len(r.Capabilities))
+	for cacheLevel := range r.Capabilities {
+		levels = append(levels, cacheLevel)
+	}
+
+	sort.Strings(levels)
+
+	for _, cacheLevel := range levels {
+		caps := r.Capabilities[cacheLevel]
+		inventory.Capabilities = append(inventory.Capabilities, InventoryCapabilities{
+			CacheLevel:    caps.CacheLevel,
+			NumClosids:    caps.NumClosids,
+			CbmMask:       fmt.Sprintf("%#x", caps.CbmMask),
+			MinCbmBits:    caps.MinCbmBits,
+			ShareableBits: fmt.Sprintf("%#x", caps.ShareableBits),
+			BytesPerBit:   caps.BytesPerBit,
+			FreeClosids:   caps.FreeClosids,
+			FreeBits:      caps.FreeBits,
+			FreeBuffers:   caps.FreeBuffers,
+		})
+	}
+
+	for _, mismatch := range r.SizeMismatches {
+		inventory.SizeMismatches = append(inventory.SizeMismatches, InventorySizeMismatch(mismatch))
+	}
+
+	return inventory
+}
+
+// JSON returns the inventory in indented JSON format.
+func (i *Inventory) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error when encoding inventory: %w", err)
+	}
+
+	return data, nil
+}
+
+// YAML returns the inventory in YAML format.
+func (i *Inventory) YAML() ([]byte, error) {
+	data, err := yaml.Marshal(i)
+	if err != nil {
+		return nil, fmt.Errorf("error when encoding inventory: %w", err)
+	}
+
+	return data, nil
+}
+
+// ParseInventory parses an inventory in JSON or YAML format. Inventories of
+// other versions are rejected.
+func ParseInventory(data []byte) (*Inventory, error) {
+	inventory := &Inventory{}
+
+	if err := yaml.Unmarshal(data, inventory); err != nil {
+		return nil, fmt.Errorf("error when decoding inventory: %w", err)
+	}
+
+	if inventory.APIVersion != InventoryVersion || inventory.Kind != InventoryKind {
+		return nil, fmt.Errorf("unsupported inventory %v of version %v", inventory.Kind, inventory.APIVersion)
+	}
+
+	return inventory, nil
+}
+
+// hexMask formats a bitmask as hexadecimal string, empty if no bit is set.
+func hexMask(mask uint64) string {
+	if mask == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%#x", mask)
+}
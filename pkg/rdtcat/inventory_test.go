@@ -0,0 +1,74 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Inventory", func() {
+	var inventory *rdtcat.Inventory
+
+	BeforeEach(func() {
+		rdtcatBuffers := rdtcat.NewBuffers(snapshotRoot)
+		rdtcatBuffers.SysRoot = snapshotSysRoot
+		Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+
+		inventory = rdtcatBuffers.Inventory()
+		inventory.Labels = map[string]string{"intel.com/excat-l3": "2560"}
+	})
+
+	Context("When creating the inventory of a snapshot", func() {
+		It("should keep classes, masks, sizes and capabilities", func() {
+			Expect(inventory.APIVersion).To(Equal(rdtcat.InventoryVersion))
+			Expect(inventory.Classes).To(HaveLen(3))
+
+			var class0 rdtcat.InventoryClass
+			for _, class := range inventory.Classes {
+				if class.Name == "class0" {
+					class0 = class
+				}
+			}
+
+			Expect(class0.Mode).To(Equal(rdtcat.ModeExclusive))
+			Expect(class0.CacheIDs).To(ContainElement(rdtcat.InventoryCacheID{
+				ID: 1, CacheLevel: "L3", Mask: "0x3", SizeKib: 2560, SharedCPUs: []int{2, 3}, NumaNodes: []int{1},
+			}))
+			Expect(class0.MbaIDs).To(ContainElement(rdtcat.InventoryMbaID{ID: 0, Bandwidth: 100}))
+
+			Expect(inventory.Capabilities).To(ConsistOf(HaveField("CacheLevel", "L3")))
+			Expect(inventory.Capabilities[0].CbmMask).To(Equal("0xfffff"))
+			Expect(inventory.Capabilities[0].NumClosids).To(Equal(16))
+		})
+	})
+
+	Context("When encoding the inventory", func() {
+		It("should decode JSON to the same inventory", func() {
+			data, err := inventory.JSON()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"apiVersion": "excat.intel.com/v1"`))
+
+			decoded, err := rdtcat.ParseInventory(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(Equal(inventory))
+		})
+
+		It("should decode YAML to the same inventory", func() {
+			data, err := inventory.YAML()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("kind: Inventory"))
+
+			decoded, err := rdtcat.ParseInventory(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(Equal(inventory))
+		})
+
+		It("should reject other versions", func() {
+			_, err := rdtcat.ParseInventory([]byte(`{"apiVersion": "excat.intel.com/v0", "kind": "Inventory"}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
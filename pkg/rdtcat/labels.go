@@ -0,0 +1,130 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+// ResourcePrefix is the prefix of all extended resources and node labels.
+const ResourcePrefix = "intel.com/"
+
+// Names of the resources advertised for buffers
+const (
+	cacheLevel2      = 2
+	cacheLevel3      = 3
+	resourceBaseName = "excat"
+	LockedSuffix     = "-locked" // suffix of resources of pseudo-locked regions
+)
+
+// Suffixes of the node labels added for each resource
+const (
+	SizeLabelSuffix = ""      // size of the buffers in KiB
+	FreeLabelSuffix = "-free" // number of exclusive buffers that can still be configured
+	MaxLabelSuffix  = "-max"  // biggest size of the buffers on any cache ID in KiB
+	CodeLabelSuffix = "-code" // size of the code partitions in KiB (CDP only)
+	DataLabelSuffix = "-data" // size of the data partitions in KiB (CDP only)
+	MbLabelSuffix   = "-mb"   // memory bandwidth allocated to the buffers (MBA only)
+	L2LabelSuffix   = "-l2"   // L2 size of buffers allocating L2 and L3 cache in KiB
+	L3LabelSuffix   = "-l3"   // L3 size of buffers allocating L2 and L3 cache in KiB
+)
+
+// LabelSuffixes lists all label suffixes used by ExCAT.
+var LabelSuffixes = []string{
+	SizeLabelSuffix, FreeLabelSuffix, MaxLabelSuffix, CodeLabelSuffix, DataLabelSuffix, MbLabelSuffix,
+	L2LabelSuffix, L3LabelSuffix,
+}
+
+// Resource keeps the name of a resource advertised for buffers and the cache
+// levels allocated by its buffers.
+type Resource struct {
+	Name        string
+	CacheLevels []int
+	Locked      bool // buffers are pseudo-locked regions
+}
+
+// Resources lists all resources that can be advertised. Classes allocating
+// both, L2 and L3 cache, are advertised as a combined resource. Pseudo-locked
+// regions are advertised as separate resources.
+var Resources = []Resource{
+	{Name: resourceBaseName + "-l2", CacheLevels: []int{cacheLevel2}},
+	{Name: resourceBaseName + "-l3", CacheLevels: []int{cacheLevel3}},
+	{Name: resourceBaseName + "-l2l3", CacheLevels: []int{cacheLevel2, cacheLevel3}},
+	{Name: resourceBaseName + "-l2" + LockedSuffix, CacheLevels: []int{cacheLevel2}, Locked: true},
+	{Name: resourceBaseName + "-l3" + LockedSuffix, CacheLevels: []int{cacheLevel3}, Locked: true},
+}
+
+// ExtractBuffers returns the buffers of the resource.
+func (res Resource) ExtractBuffers(rdtBuffers *Buffers) *Buffers {
+	if res.Locked {
+		return rdtBuffers.ExtractLockedBuffers(res.CacheLevels[0])
+	}
+
+	return rdtBuffers.ExtractBuffers(res.CacheLevels...)
+}
+
+// ResourceLabels returns the labels of the buffers of the given resource keyed
+// by label suffix. Empty labels are omitted. Labels have to be created
+// beforehand.
+func (r *Buffers) ResourceLabels(res Resource) map[string]string {
+	var (
+		size        string
+		levelLabels LevelLabels
+	)
+
+	if len(res.CacheLevels) > 1 {
+		return omitEmptyLabels(map[string]string{
+			L2LabelSuffix: r.DpL2L3Labels.L2,
+			L3LabelSuffix: r.DpL2L3Labels.L3,
+			MbLabelSuffix: r.DpL2L3Labels.Mb,
+		})
+	}
+
+	switch res.CacheLevels[0] {
+	case cacheLevel2:
+		size, levelLabels = r.DpL2Label, r.DpL2Labels
+	case cacheLevel3:
+		size, levelLabels = r.DpL3Label, r.DpL3Labels
+	}
+
+	// pseudo-locked regions are labelled with their size only
+	if res.Locked {
+		return omitEmptyLabels(map[string]string{SizeLabelSuffix: levelLabels.Locked})
+	}
+
+	return omitEmptyLabels(map[string]string{
+		SizeLabelSuffix: size,
+		FreeLabelSuffix: levelLabels.Free,
+		MaxLabelSuffix:  levelLabels.Max,
+		CodeLabelSuffix: levelLabels.Code,
+		DataLabelSuffix: levelLabels.Data,
+		MbLabelSuffix:   levelLabels.Mb,
+	})
+}
+
+// NodeLabels returns the labels of all resources with buffers keyed by label
+// name, as added to the node by the device plugin. Labels have to be created
+// beforehand.
+func (r *Buffers) NodeLabels() map[string]string {
+	labels := map[string]string{}
+
+	for _, res := range Resources {
+		if !res.ExtractBuffers(r).HasClasses() {
+			continue
+		}
+
+		for suffix, value := range r.ResourceLabels(res) {
+			labels[ResourcePrefix+res.Name+suffix] = value
+		}
+	}
+
+	return labels
+}
+
+// omitEmptyLabels removes all labels with empty value.
+func omitEmptyLabels(labels map[string]string) map[string]string {
+	for suffix, value := range labels {
+		if value == "" {
+			delete(labels, suffix)
+		}
+	}
+
+	return labels
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"testing/fstest"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Labels", func() {
+	Context("When creating the node labels of a snapshot", func() {
+		It("should return the labels of all resources with buffers", func() {
+			rdtcatBuffers := rdtcat.NewBuffers(snapshotRoot)
+			rdtcatBuffers.SysRoot = snapshotSysRoot
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.CreateLabels()).To(Succeed())
+
+			labels := rdtcatBuffers.NodeLabels()
+			Expect(labels).To(HaveKeyWithValue("intel.com/excat-l3", rdtcatBuffers.DpL3Label))
+			Expect(labels).To(HaveKeyWithValue("intel.com/excat-l3-free", rdtcatBuffers.DpL3Labels.Free))
+			Expect(labels).NotTo(HaveKey("intel.com/excat-l2"))
+		})
+
+		It("should skip levels where the default class is the only class", func() {
+			rdtcatBuffers := rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":               {Data: []byte("L2:0=ff\nL3:0=fffff\n")},
+				"size":                   {Data: []byte("L2:0=1048576\nL3:0=20971520\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"c0/schemata":            {Data: []byte("L3:0=0000f\n")},
+				"c0/size":                {Data: []byte("L3:0=4194304\n")},
+				"c0/tasks":               {Data: []byte("")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+				"info/L3/num_closids":    {Data: []byte("16\n")},
+				"info/L3/cbm_mask":       {Data: []byte("fffff\n")},
+				"info/L3/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L3/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.CreateLabels()).To(Succeed())
+
+			l2l3 := rdtcatBuffers.ExtractBuffers(2, 3)
+			Expect(l2l3.ResctrlGroups).To(ConsistOf(HaveField("Name", rdtcat.DefaultClass)))
+			Expect(l2l3.HasClasses()).To(BeFalse())
+
+			labels := rdtcatBuffers.NodeLabels()
+			Expect(labels).To(HaveKey("intel.com/excat-l3"))
+			Expect(labels).NotTo(HaveKey(HavePrefix("intel.com/excat-l2")))
+		})
+	})
+})
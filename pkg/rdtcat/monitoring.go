@@ -0,0 +1,214 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// monitoring event files in the mon_data directories of a class
+const (
+	llcOccupancyFile  = "llc_occupancy"
+	mbmTotalBytesFile = "mbm_total_bytes"
+	mbmLocalBytesFile = "mbm_local_bytes"
+)
+
+// MonSample keeps the monitoring data of one class on one L3 cache ID as
+// reported in mon_data/mon_L3_<cache ID>. Err keeps the errors when reading
+// the events of the cache ID, the other events are read nevertheless.
+type MonSample struct {
+	Class        string
+	CacheID      int
+	Time         time.Time
+	LlcOccupancy MonCounter // bytes currently occupied in L3 cache
+	MbmTotal     MonCounter // total memory bandwidth counter in bytes
+	MbmLocal     MonCounter // local memory bandwidth counter in bytes
+	Err          error
+}
+
+// MonCounter keeps the reading of one monitoring event. Readings of events
+// that are not supported, reported as unavailable by the kernel or failed are
+// not Valid and keep the Value of the last valid reading, 0 if there is none.
+// For memory bandwidth counters, Delta covers the Interval since the last
+// valid reading and is 0 for the first valid reading.
+type MonCounter struct {
+	Value    uint64
+	Valid    bool
+	Delta    uint64
+	Interval time.Duration
+	readAt   time.Time // time of the last valid reading
+}
+
+// Bandwidth returns the bytes per second within the interval, 0 without
+// interval.
+func (c MonCounter) Bandwidth() float64 {
+	if c.Interval <= 0 {
+		return 0
+	}
+
+	return float64(c.Delta) / c.Interval.Seconds()
+}
+
+// update completes a reading of the counter taken at the given time with the
+// last valid reading. Counters decreasing in between, e.g. for a class that
+// was recreated, are handled like a first reading.
+func (c *MonCounter) update(previous MonCounter, now time.Time) {
+	if !c.Valid {
+		*c = MonCounter{Value: previous.Value, readAt: previous.readAt}
+
+		return
+	}
+
+	c.readAt = now
+
+	if previous.readAt.IsZero() || c.Value < previous.Value {
+		return
+	}
+
+	c.Interval = now.Sub(previous.readAt)
+	c.Delta = c.Value - previous.Value
+}
+
+// monKey identifies the monitoring data of one class on one cache ID.
+type monKey struct {
+	class   string
+	cacheID int
+}
+
+// Monitor reads the monitoring data of all classes in the resctrl root and
+// computes the deltas between consecutive valid readings.
+type Monitor struct {
+	resctrl  *Resctrl
+	previous map[monKey]MonSample
+}
+
+// NewMonitor returns a Monitor reading through the given Resctrl.
+func NewMonitor(resctrl *Resctrl) *Monitor {
+	return &Monitor{
+		resctrl:  resctrl,
+		previous: map[monKey]MonSample{},
+	}
+}
+
+// Sample reads the current monitoring data of all classes. Classes without
+// mon_data, e.g. if monitoring is not supported, are skipped. Errors when
+// reading the events of a class on a cache ID are kept in the sample.
+func (m *Monitor) Sample() ([]MonSample, error) {
+	names, err := m.resctrl.ExcatBuffers.GetClassNames()
+	if err != nil {
+		return nil, fmt.Errorf("error when reading in class names: %w", err)
+	}
+
+	now := time.Now()
+	current := map[monKey]MonSample{}
+
+	var samples []MonSample
+
+	for _, name := range names {
+		for _, sample := range m.resctrl.readMonData(name) {
+			sample.Time = now
+
+			key := monKey{class: sample.Class, cacheID: sample.CacheID}
+			previous := m.previous[key]
+
+			sample.LlcOccupancy.update(previous.LlcOccupancy, now)
+			sample.MbmTotal.update(previous.MbmTotal, now)
+			sample.MbmLocal.update(previous.MbmLocal, now)
+
+			// occupancy is no counter
+			sample.LlcOccupancy.Delta, sample.LlcOccupancy.Interval = 0, 0
+
+			current[key] = sample
+			samples = append(samples, sample)
+		}
+	}
+
+	// classes removed in the meantime are forgotten
+	m.previous = current
+
+	return samples, nil
+}
+
+// readMonData reads the monitoring data of a class on each L3 cache ID.
+// Directories without a valid cache ID are skipped.
+func (r *Resctrl) readMonData(class string) []MonSample {
+	classDir := class
+	if class == DefaultClass {
+		classDir = "."
+	}
+
+	fsys := r.getFS()
+
+	// the pattern is valid, so Glob doesn't fail
+	dirs, _ := fs.Glob(fsys, path.Join(classDir, "mon_data", "mon_L3_*"))
+	samples := make([]MonSample, 0, len(dirs))
+
+	for _, dir := range dirs {
+		cacheID, err := strconv.Atoi(strings.TrimPrefix(path.Base(dir), "mon_L3_"))
+		if err != nil {
+			log.Warn().Err(err).Msgf("invalid cache ID in %v", dir)
+
+			continue
+		}
+
+		sample := MonSample{Class: class, CacheID: cacheID}
+
+		var errs []error
+
+		for _, event := range []struct {
+			file    string
+			counter *MonCounter
+		}{
+			{llcOccupancyFile, &sample.LlcOccupancy},
+			{mbmTotalBytesFile, &sample.MbmTotal},
+			{mbmLocalBytesFile, &sample.MbmLocal},
+		} {
+			value, valid, err := readMonEvent(fsys, path.Join(dir, event.file))
+			if err != nil {
+				errs = append(errs, err)
+			}
+
+			event.counter.Value, event.counter.Valid = value, valid
+		}
+
+		sample.Err = errors.Join(errs...)
+		samples = append(samples, sample)
+	}
+
+	return samples
+}
+
+// readMonEvent reads a monitoring event file. Missing events and events
+// reported as unavailable by the kernel are not valid. Events the kernel
+// failed to read are returned as ErrMonitoringEvent.
+func readMonEvent(fsys fs.FS, name string) (uint64, bool, error) {
+	value, err := readSysfsFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	switch value {
+	case "Unavailable":
+		return 0, false, nil
+	case "Error":
+		return 0, false, fmt.Errorf("%w: %v", ErrMonitoringEvent, name)
+	}
+
+	count, err := strconv.ParseUint(value, 10, 64) //nolint:gomnd // decimal 64 bit counter
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid monitoring data in %v: %w", name, err)
+	}
+
+	return count, true, nil
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"testing/fstest"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Monitoring", func() {
+	Context("When reading a snapshot of /sys/fs/resctrl", func() {
+		It("should read the monitoring data of each cache ID", func() {
+			monitor := rdtcat.NewMonitor(rdtcat.NewResctrl(snapshotRoot))
+
+			samples, err := monitor.Sample()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(samples).To(HaveLen(2))
+			Expect(samples[0].Class).To(Equal("class0"))
+			Expect(samples[0].CacheID).To(Equal(0))
+			Expect(samples[0].LlcOccupancy.Value).To(Equal(uint64(2490368)))
+			Expect(samples[0].MbmTotal.Value).To(Equal(uint64(1073741824)))
+			Expect(samples[0].MbmTotal.Valid).To(BeTrue())
+			Expect(samples[0].MbmLocal.Value).To(Equal(uint64(805306368)))
+			Expect(samples[0].MbmTotal.Delta).To(BeZero())
+			Expect(samples[0].Err).NotTo(HaveOccurred())
+			Expect(samples[1].CacheID).To(Equal(1))
+			Expect(samples[1].LlcOccupancy.Valid).To(BeTrue())
+			Expect(samples[1].MbmTotal.Valid).To(BeFalse(), "unavailable events should be marked")
+			Expect(samples[1].MbmTotal.Value).To(BeZero())
+		})
+	})
+
+	Context("When counters change between samples", func() {
+		var (
+			fsys    fstest.MapFS
+			monitor *rdtcat.Monitor
+		)
+
+		BeforeEach(func() {
+			fsys = fstest.MapFS{
+				"schemata":                              {Data: []byte("L3:0=f00\n")},
+				"tasks":                                 {Data: []byte("1\n")},
+				"mon_data/mon_L3_00/llc_occupancy":      {Data: []byte("1024\n")},
+				"mon_data/mon_L3_00/mbm_total_bytes":    {Data: []byte("1000\n")},
+				"c0/tasks":                              {Data: []byte("")},
+				"c0/mon_data/mon_L3_00/llc_occupancy":   {Data: []byte("2048\n")},
+				"c0/mon_data/mon_L3_00/mbm_total_bytes": {Data: []byte("5000\n")},
+				"c0/mon_data/mon_L3_00/mbm_local_bytes": {Data: []byte("4000\n")},
+			}
+			monitor = rdtcat.NewMonitor(rdtcat.NewResctrlFS("/snapshot", fsys))
+		})
+
+		It("should compute the deltas to the previous sample", func() {
+			Expect(monitor.Sample()).To(HaveLen(2))
+
+			fsys["c0/mon_data/mon_L3_00/mbm_total_bytes"] = &fstest.MapFile{Data: []byte("8000\n")}
+			fsys["c0/mon_data/mon_L3_00/mbm_local_bytes"] = &fstest.MapFile{Data: []byte("6000\n")}
+
+			samples, err := monitor.Sample()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(samples).To(HaveLen(2))
+			Expect(samples[0].Class).To(Equal("c0"))
+			Expect(samples[0].MbmTotal.Delta).To(Equal(uint64(3000)))
+			Expect(samples[0].MbmLocal.Delta).To(Equal(uint64(2000)))
+			Expect(samples[0].MbmTotal.Interval).To(BeNumerically(">", 0))
+			Expect(samples[0].MbmTotal.Bandwidth()).To(BeNumerically(">", 0))
+			Expect(samples[0].LlcOccupancy.Delta).To(BeZero())
+			Expect(samples[1].Class).To(Equal(rdtcat.DefaultClass))
+			Expect(samples[1].MbmTotal.Delta).To(BeZero())
+			Expect(samples[1].MbmLocal.Valid).To(BeFalse(), "missing events should be marked")
+			Expect(samples[1].MbmLocal.Value).To(BeZero())
+		})
+
+		It("should keep the last valid reading while a counter is unavailable", func() {
+			first, err := monitor.Sample()
+			Expect(err).NotTo(HaveOccurred())
+
+			fsys["c0/mon_data/mon_L3_00/mbm_total_bytes"] = &fstest.MapFile{Data: []byte("Unavailable\n")}
+			fsys["c0/mon_data/mon_L3_00/llc_occupancy"] = &fstest.MapFile{Data: []byte("Unavailable\n")}
+
+			samples, err := monitor.Sample()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(samples[0].MbmTotal.Valid).To(BeFalse())
+			Expect(samples[0].MbmTotal.Value).To(Equal(uint64(5000)))
+			Expect(samples[0].MbmTotal.Delta).To(BeZero())
+			Expect(samples[0].LlcOccupancy.Valid).To(BeFalse())
+			Expect(samples[0].LlcOccupancy.Value).To(Equal(uint64(2048)))
+			Expect(samples[0].Err).NotTo(HaveOccurred())
+
+			fsys["c0/mon_data/mon_L3_00/mbm_total_bytes"] = &fstest.MapFile{Data: []byte("9000\n")}
+
+			samples, err = monitor.Sample()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(samples[0].MbmTotal.Valid).To(BeTrue())
+			Expect(samples[0].MbmTotal.Delta).To(Equal(uint64(4000)), "delta to the last valid reading")
+			Expect(samples[0].MbmTotal.Interval).To(BeNumerically(">=", samples[0].Time.Sub(first[0].Time)))
+		})
+
+		It("should handle reset counters like a first sample", func() {
+			Expect(monitor.Sample()).To(HaveLen(2))
+
+			fsys["c0/mon_data/mon_L3_00/mbm_total_bytes"] = &fstest.MapFile{Data: []byte("100\n")}
+
+			samples, err := monitor.Sample()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(samples[0].MbmTotal.Delta).To(BeZero())
+			Expect(samples[0].MbmTotal.Interval).To(BeZero())
+		})
+
+		It("should report failed events with the class and cache ID", func() {
+			Expect(monitor.Sample()).To(HaveLen(2))
+
+			fsys["c0/mon_data/mon_L3_00/llc_occupancy"] = &fstest.MapFile{Data: []byte("Error\n")}
+			fsys["c0/mon_data/mon_L3_00/mbm_total_bytes"] = &fstest.MapFile{Data: []byte("6000\n")}
+
+			samples, err := monitor.Sample()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(samples).To(HaveLen(2))
+			Expect(samples[0].Err).To(MatchError(rdtcat.ErrMonitoringEvent))
+			Expect(samples[0].LlcOccupancy.Valid).To(BeFalse())
+			Expect(samples[0].LlcOccupancy.Value).To(Equal(uint64(2048)))
+			Expect(samples[0].MbmTotal.Delta).To(Equal(uint64(1000)))
+			Expect(samples[1].Err).NotTo(HaveOccurred())
+		})
+
+		It("should report malformed counters with the class and cache ID", func() {
+			fsys["c0/mon_data/mon_L3_00/mbm_local_bytes"] = &fstest.MapFile{Data: []byte("lots\n")}
+
+			samples, err := monitor.Sample()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(samples[0].Err).To(MatchError(ContainSubstring("mbm_local_bytes")))
+			Expect(samples[0].MbmLocal.Valid).To(BeFalse())
+			Expect(samples[0].MbmTotal.Valid).To(BeTrue())
+		})
+	})
+})
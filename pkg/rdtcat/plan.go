@@ -0,0 +1,253 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"fmt"
+	"math/bits"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Names of the partitions and classes in a planned RDT configuration. Buffer
+// i is kept in partition p<i> with class c<i>.
+const (
+	DefaultPartition = "pdef"
+	partitionPrefix  = "p"
+	classPrefix      = "c"
+)
+
+// PlanRequest describes a buffer layout of NumBuffers exclusive buffers of at
+// least SizeKib each on the given cache level, plus the default class getting
+// the remainder of the cache.
+type PlanRequest struct {
+	CacheLevel int
+	NumBuffers int
+	SizeKib    int
+}
+
+// PlannedBuffer keeps the bitmask of a planned partition and its class. The
+// same bitmask is used on all cache IDs.
+type PlannedBuffer struct {
+	Partition string
+	Class     string
+	Mask      uint64
+	SizeKib   map[int]int // size keyed by cache ID
+}
+
+// Plan keeps a buffer layout of one cache level whose partitions don't
+// overlap.
+type Plan struct {
+	CacheLevel string
+	Buffers    []PlannedBuffer
+	Default    PlannedBuffer
+}
+
+// PlanBuffers plans a layout of exclusive buffers on the cache IDs read from
+// resctrl. Each buffer gets the same number of bits, enough to provide the
+// requested size on every cache ID. The layout is checked against num_closids,
+// min_cbm_bits and the shareable bits. Buffers and capabilities have to be
+// read beforehand. Layouts with CDP are not supported.
+func (r *Buffers) PlanBuffers(req PlanRequest) (*Plan, error) {
+	cacheLevel := fmt.Sprintf("L%v", req.CacheLevel)
+
+	caps, ok := r.Capabilities[cacheLevel]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v cache not allocated by any class", ErrRDTUnsupported, cacheLevel)
+	}
+
+	if r.IsCdp() {
+		return nil, fmt.Errorf("planning layouts with CDP is not supported")
+	}
+
+	if req.NumBuffers < 1 || req.SizeKib < 1 {
+		return nil, fmt.Errorf("invalid request for %v buffers of %v KiB", req.NumBuffers, req.SizeKib)
+	}
+
+	if req.NumBuffers+1 > caps.NumClosids {
+		return nil, fmt.Errorf("%w: %v buffers and the default class need more than %v CLOS",
+			ErrInsufficientCapacity, req.NumBuffers, caps.NumClosids)
+	}
+
+	bytesPerBit, err := r.planBytesPerBit(cacheLevel, caps)
+	if err != nil {
+		return nil, err
+	}
+
+	minBits := caps.MinCbmBits
+	if minBits < 1 {
+		minBits = 1
+	}
+
+	// the cache ID with the smallest bits needs the most bits per buffer
+	bufferBits := minBits
+
+	for _, perBit := range bytesPerBit {
+		needed := (kib2bytes(req.SizeKib) + perBit - 1) / perBit
+		if needed > bufferBits {
+			bufferBits = needed
+		}
+	}
+
+	usedBits := req.NumBuffers * bufferBits
+	if usedBits > caps.CbmBits-minBits {
+		return nil, fmt.Errorf("%w: %v buffers of %v bits leave less than %v of %v bits to the default class",
+			ErrInsufficientCapacity, req.NumBuffers, bufferBits, minBits, caps.CbmBits)
+	}
+
+	lsb, err := placeBuffers(caps, usedBits)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{CacheLevel: cacheLevel}
+
+	for ind := 0; ind < req.NumBuffers; ind++ {
+		mask := ((uint64(1) << bufferBits) - 1) << (lsb + ind*bufferBits)
+
+		plan.Buffers = append(plan.Buffers, PlannedBuffer{
+			Partition: fmt.Sprintf("%v%v", partitionPrefix, ind),
+			Class:     fmt.Sprintf("%v%v", classPrefix, ind),
+			Mask:      mask,
+			SizeKib:   plannedSizes(mask, bytesPerBit),
+		})
+	}
+
+	usedMask := ((uint64(1) << usedBits) - 1) << lsb
+	defaultMask := caps.CbmMask &^ usedMask
+
+	plan.Default = PlannedBuffer{
+		Partition: DefaultPartition,
+		Class:     DefaultClass,
+		Mask:      defaultMask,
+		SizeKib:   plannedSizes(defaultMask, bytesPerBit),
+	}
+
+	if err := r.verifyPlan(plan, caps); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// planBytesPerBit returns the cache size allocated by one bit on each cache
+// ID of a cache level. The cache geometry from sysfs is preferred, the size
+// reported by resctrl is the fallback.
+func (r *Buffers) planBytesPerBit(cacheLevel string, caps CacheCapabilities) (map[int]int, error) {
+	ids := r.levelCacheIDs(cacheLevel)
+	bytesPerBit := make(map[int]int, len(ids))
+	cbmBits := map[string]int{}
+
+	for _, id := range ids {
+		perBit, err := r.bytesPerBit(&CacheID{CacheLevel: cacheLevel, ID: id}, cbmBits)
+		if err != nil {
+			return nil, err
+		}
+
+		if perBit == 0 {
+			perBit = caps.BytesPerBit
+		}
+
+		if perBit == 0 {
+			return nil, fmt.Errorf("size of %v cache ID %v unknown", cacheLevel, id)
+		}
+
+		bytesPerBit[id] = perBit
+	}
+
+	if len(bytesPerBit) == 0 {
+		return nil, fmt.Errorf("no %v cache IDs found", cacheLevel)
+	}
+
+	return bytesPerBit, nil
+}
+
+// placeBuffers returns the lowest bit of a contiguous block of usedBits bits
+// for the exclusive buffers. The block is placed at the low or high end of the
+// bitmask, so that the default class keeps one contiguous block, and must not
+// overlap with the shareable bits.
+func placeBuffers(caps CacheCapabilities, usedBits int) (int, error) {
+	block := (uint64(1) << usedBits) - 1
+
+	for _, lsb := range []int{0, caps.CbmBits - usedBits} {
+		if (block<<lsb)&caps.ShareableBits == 0 {
+			return lsb, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: no %v contiguous bits without shareable bits %#x",
+		ErrInsufficientCapacity, usedBits, caps.ShareableBits)
+}
+
+// plannedSizes returns the size of a bitmask keyed by cache ID.
+func plannedSizes(mask uint64, bytesPerBit map[int]int) map[int]int {
+	sizes := make(map[int]int, len(bytesPerBit))
+	for id, perBit := range bytesPerBit {
+		sizes[id] = bytes2kib(perBit * bits.OnesCount64(mask))
+	}
+
+	return sizes
+}
+
+// verifyPlan resolves the RDT configuration of a plan like goresctrl and
+// checks that each partition gets its planned bitmask on every cache ID.
+func (r *Buffers) verifyPlan(plan *Plan, caps CacheCapabilities) error {
+	data, err := plan.RdtConfig()
+	if err != nil {
+		return err
+	}
+
+	config, err := ParseRdtConfig(data)
+	if err != nil {
+		return err
+	}
+
+	grants, err := resolvePartitions(config, plan.CacheLevel, caps, r.levelCacheIDs(plan.CacheLevel))
+	if err != nil {
+		return fmt.Errorf("planned layout is invalid: %w", err)
+	}
+
+	for _, buffer := range append([]PlannedBuffer{plan.Default}, plan.Buffers...) {
+		for id := range buffer.SizeKib {
+			if mask := grants[buffer.Partition][id][""]; mask != buffer.Mask {
+				return fmt.Errorf("planned layout is invalid: partition %v gets %#x instead of %#x on cache ID %v",
+					buffer.Partition, mask, buffer.Mask, id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RdtConfig returns the RDT configuration of the plan in YAML format as used
+// by containerd and cri-o, e.g. in /etc/rdt-config.yaml. The planned cache
+// level is required, all other allocations are optional.
+func (p *Plan) RdtConfig() ([]byte, error) {
+	allocationKey := fmt.Sprintf("l%vAllocation", p.CacheLevel[1:])
+
+	options := map[string]map[string]bool{}
+	for _, level := range []string{"l2", "l3", "mb"} {
+		options[level] = map[string]bool{"optional": level != "l"+p.CacheLevel[1:]}
+	}
+
+	partitions := map[string]map[string]any{}
+	for _, buffer := range append([]PlannedBuffer{p.Default}, p.Buffers...) {
+		partitions[buffer.Partition] = map[string]any{
+			allocationKey: fmt.Sprintf("%#x", buffer.Mask),
+			"classes": map[string]map[string]string{
+				buffer.Class: {allocationKey: "100%"},
+			},
+		}
+	}
+
+	data, err := yaml.Marshal(map[string]any{
+		"options":    options,
+		"partitions": partitions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when writing RDT config: %w", err)
+	}
+
+	return data, nil
+}
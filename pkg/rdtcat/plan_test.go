@@ -0,0 +1,69 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plan", func() {
+	var rdtcatBuffers *rdtcat.Buffers
+
+	BeforeEach(func() {
+		rdtcatBuffers = rdtcat.NewBuffers(snapshotRoot)
+		rdtcatBuffers.SysRoot = snapshotSysRoot
+		Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+	})
+
+	Context("When the buffers fit into the cache", func() {
+		It("should plan non-overlapping partitions and the default remainder", func() {
+			plan, err := rdtcatBuffers.PlanBuffers(rdtcat.PlanRequest{CacheLevel: 3, NumBuffers: 3, SizeKib: 2000})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(plan.Buffers).To(Equal([]rdtcat.PlannedBuffer{
+				{Partition: "p0", Class: "c0", Mask: 0x3, SizeKib: map[int]int{0: 2560, 1: 2560}},
+				{Partition: "p1", Class: "c1", Mask: 0xc, SizeKib: map[int]int{0: 2560, 1: 2560}},
+				{Partition: "p2", Class: "c2", Mask: 0x30, SizeKib: map[int]int{0: 2560, 1: 2560}},
+			}))
+			Expect(plan.Default).To(Equal(rdtcat.PlannedBuffer{
+				Partition: "pdef", Class: "system/default", Mask: 0xfffc0, SizeKib: map[int]int{0: 17920, 1: 17920},
+			}))
+		})
+
+		It("should write an RDT config goresctrl resolves to the plan", func() {
+			plan, err := rdtcatBuffers.PlanBuffers(rdtcat.PlanRequest{CacheLevel: 3, NumBuffers: 2, SizeKib: 1280})
+			Expect(err).NotTo(HaveOccurred())
+
+			data, err := plan.RdtConfig()
+			Expect(err).NotTo(HaveOccurred())
+
+			config, err := rdtcat.ParseRdtConfig(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Partitions).To(HaveLen(3))
+			Expect(config.Partitions["p1"].L3Allocation["all"].Unified).To(BeEquivalentTo("0x2"))
+			Expect(config.Partitions["pdef"].Classes).To(HaveKey("system/default"))
+			Expect(config.Options.L3.Optional).To(BeFalse())
+			Expect(config.Options.L2.Optional).To(BeTrue())
+		})
+	})
+
+	Context("When the buffers don't fit", func() {
+		It("should return an error if the default class gets too few bits", func() {
+			_, err := rdtcatBuffers.PlanBuffers(rdtcat.PlanRequest{CacheLevel: 3, NumBuffers: 10, SizeKib: 2560})
+			Expect(err).To(MatchError(rdtcat.ErrInsufficientCapacity))
+		})
+
+		It("should return an error if the CLOS are exhausted", func() {
+			_, err := rdtcatBuffers.PlanBuffers(rdtcat.PlanRequest{CacheLevel: 3, NumBuffers: 16, SizeKib: 1})
+			Expect(err).To(MatchError(rdtcat.ErrInsufficientCapacity))
+		})
+
+		It("should return an error for cache levels without classes", func() {
+			_, err := rdtcatBuffers.PlanBuffers(rdtcat.PlanRequest{CacheLevel: 2, NumBuffers: 1, SizeKib: 1})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,217 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ProcfsPath is the default mount point of procfs.
+	ProcfsPath = "/proc"
+	// AllocationEnv is the environment variable the device plugin sets in the
+	// containers a buffer is allocated to. Its value identifies the allocation.
+	AllocationEnv = "EXCAT_ALLOCATION"
+)
+
+var (
+	// podUIDRegexp matches the pod UID in a cgroup path. The systemd cgroup
+	// driver replaces the dashes of the UID with underscores.
+	podUIDRegexp = regexp.MustCompile(
+		`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+	// containerIDRegexp matches the container ID in the last element of a
+	// cgroup path, e.g. "<id>", "cri-containerd-<id>.scope" or "crio-<id>.scope".
+	containerIDRegexp = regexp.MustCompile(`^(?:[a-z-]+-)?([0-9a-f]{64})(?:\.scope)?$`)
+)
+
+// TaskOwner keeps the pod and container a task belongs to. PodUID and
+// ContainerID are empty for tasks not running in a Kubernetes container.
+// Allocation is the value of AllocationEnv in the environment of the task.
+type TaskOwner struct {
+	PID         string `json:"pid"`
+	Cgroup      string `json:"cgroup"` // cgroup path the IDs are read from
+	PodUID      string `json:"podUid,omitempty"`
+	ContainerID string `json:"containerId,omitempty"`
+	Allocation  string `json:"allocation,omitempty"`
+}
+
+// String returns a human readable description of the task owner.
+func (o TaskOwner) String() string {
+	if o.ContainerID == "" {
+		return fmt.Sprintf("PID %v (cgroup %v)", o.PID, o.Cgroup)
+	}
+
+	return fmt.Sprintf("PID %v (pod %v, container %v)", o.PID, o.PodUID, o.ContainerID)
+}
+
+// GetProcRoot returns the procfs root directory.
+func (r *Resctrl) GetProcRoot() string {
+	if r.ProcRoot == "" {
+		return ProcfsPath
+	}
+
+	return r.ProcRoot
+}
+
+// getProcFS returns the filesystem rooted at the procfs root directory.
+func (r *Resctrl) getProcFS() fs.FS {
+	if r.ProcFS == nil {
+		r.ProcFS = os.DirFS(r.GetProcRoot())
+	}
+
+	return r.ProcFS
+}
+
+// ResolveTask maps a PID to its pod and container by means of the cgroup
+// the task is assigned to in /proc/<pid>/cgroup. The unified hierarchy of
+// cgroup v2 is preferred, with cgroup v1 the first hierarchy naming a
+// container is used.
+func (r *Resctrl) ResolveTask(pid string) (TaskOwner, error) {
+	owner := TaskOwner{PID: pid}
+
+	data, err := fs.ReadFile(r.getProcFS(), path.Join(pid, "cgroup"))
+	if err != nil {
+		return owner, fmt.Errorf("error when reading cgroup of PID %v: %w", pid, err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		// hierarchy ID:controllers:path
+		fields := strings.SplitN(line, ":", 3) //nolint:gomnd // fields of a cgroup line
+		if len(fields) != 3 {                  //nolint:gomnd // fields of a cgroup line
+			continue
+		}
+
+		cgroup := fields[2]
+		podUID, containerID := parseCgroup(cgroup)
+
+		if owner.Cgroup == "" || fields[0] == "0" || (owner.ContainerID == "" && containerID != "") {
+			owner.Cgroup, owner.PodUID, owner.ContainerID = cgroup, podUID, containerID
+		}
+
+		if fields[0] == "0" {
+			break
+		}
+	}
+
+	owner.Allocation = r.readAllocation(pid)
+
+	return owner, nil
+}
+
+// readAllocation returns the value of AllocationEnv in /proc/<pid>/environ,
+// empty if the variable isn't set or the environment can't be read.
+func (r *Resctrl) readAllocation(pid string) string {
+	data, err := fs.ReadFile(r.getProcFS(), path.Join(pid, "environ"))
+	if err != nil {
+		log.Debug().Err(err).Msgf("cannot read environment of PID %v", pid)
+
+		return ""
+	}
+
+	for _, variable := range strings.Split(string(data), "\x00") {
+		if value, found := strings.CutPrefix(variable, AllocationEnv+"="); found {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// parseCgroup returns the pod UID and container ID of a cgroup path as
+// created by the cgroupfs or systemd cgroup driver.
+func parseCgroup(cgroup string) (string, string) {
+	var podUID, containerID string
+
+	if match := podUIDRegexp.FindStringSubmatch(cgroup); match != nil {
+		podUID = strings.ReplaceAll(match[1], "_", "-")
+	}
+
+	if match := containerIDRegexp.FindStringSubmatch(path.Base(cgroup)); match != nil {
+		containerID = match[1]
+	}
+
+	return podUID, containerID
+}
+
+// ResolveTasks returns the owners of all tasks assigned to the class located
+// in the given directory. Tasks that exit while being resolved are skipped.
+func (r *Resctrl) ResolveTasks(classPath string) ([]TaskOwner, error) {
+	pids, err := r.GetBufferPids(path.Join(classPath, "tasks"))
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make([]TaskOwner, 0, len(pids))
+
+	for _, pid := range pids {
+		owner, err := r.ResolveTask(pid)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Debug().Msgf("PID %v exited.", pid)
+
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		owners = append(owners, owner)
+	}
+
+	return owners, nil
+}
+
+// TaskOwners returns the owners of the tasks of all classes keyed by class
+// name, i.e. which pod and container sits in which buffer. Buffers have to be
+// read beforehand.
+func (r *Resctrl) TaskOwners() (map[string][]TaskOwner, error) {
+	owners := make(map[string][]TaskOwner, len(r.ResctrlGroups))
+
+	for _, group := range r.ResctrlGroups {
+		classOwners, err := r.ResolveTasks(group.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		owners[group.Name] = classOwners
+	}
+
+	return owners, nil
+}
+
+// AllocatedContainer returns the container whose tasks carry the given
+// allocation, empty if no task running in a container carries it.
+func AllocatedContainer(tasks []TaskOwner, allocation string) string {
+	if allocation == "" {
+		return ""
+	}
+
+	for _, task := range tasks {
+		if task.ContainerID != "" && task.Allocation == allocation {
+			return task.ContainerID
+		}
+	}
+
+	return ""
+}
+
+// ForeignTasks returns the tasks not belonging to the given container. Tasks
+// not running in a container are always foreign.
+func ForeignTasks(tasks []TaskOwner, containerID string) []TaskOwner {
+	var foreign []TaskOwner
+
+	for _, task := range tasks {
+		if task.ContainerID == "" || task.ContainerID != containerID {
+			foreign = append(foreign, task)
+		}
+	}
+
+	return foreign
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"testing/fstest"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Procfs", func() {
+	var resctrl *rdtcat.Resctrl
+
+	BeforeEach(func() {
+		resctrl = rdtcat.NewResctrl(snapshotRoot)
+		resctrl.ProcRoot = snapshotProcRoot
+	})
+
+	Context("When resolving tasks of a snapshot", func() {
+		It("should map PIDs to pods and containers of both cgroup drivers", func() {
+			owners, err := resctrl.ResolveTasks(snapshotRoot)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(owners).To(HaveLen(3))
+
+			Expect(owners[0]).To(Equal(rdtcat.TaskOwner{PID: "1", Cgroup: "/init.scope"}))
+			Expect(owners[1].PodUID).To(Equal("7b1e9f3a-5c2d-4e8f-9a1b-2c3d4e5f6a7b"))
+			Expect(owners[1].ContainerID).To(Equal("4f1c2b9e8d7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e"))
+			Expect(owners[2].PodUID).To(Equal("1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d"))
+			Expect(owners[2].ContainerID).To(Equal("9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d"))
+		})
+
+		It("should read the allocation from the environment of the tasks", func() {
+			owners, err := resctrl.ResolveTasks(snapshotRoot)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(owners).To(HaveLen(3))
+
+			Expect(owners[0].Allocation).To(BeEmpty())
+			Expect(owners[1].Allocation).To(BeEmpty())
+			Expect(owners[2].Allocation).To(Equal("class0:8f3a2c1d9e4b7a60"))
+		})
+
+		It("should return the owners of all classes", func() {
+			rdtcatBuffers := rdtcat.NewBuffers(snapshotRoot)
+			rdtcatBuffers.SysRoot = snapshotSysRoot
+			rdtcatBuffers.ProcRoot = snapshotProcRoot
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+
+			owners, err := rdtcatBuffers.TaskOwners()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(owners).To(HaveKeyWithValue("class0", BeEmpty()))
+			Expect(owners).To(HaveKeyWithValue(rdtcat.DefaultClass, HaveLen(3)))
+		})
+	})
+
+	Context("When a task exits", func() {
+		It("should skip the task", func() {
+			resctrl.ProcFS = fstest.MapFS{
+				"1/cgroup": {Data: []byte("0::/init.scope\n")},
+			}
+
+			owners, err := resctrl.ResolveTasks(snapshotRoot)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(owners).To(ConsistOf(HaveField("PID", "1")))
+		})
+	})
+
+	Context("When checking tasks against the container a buffer is allocated to", func() {
+		It("should return tasks of other containers and the host", func() {
+			owners, err := resctrl.ResolveTasks(snapshotRoot)
+			Expect(err).NotTo(HaveOccurred())
+
+			containerID := rdtcat.AllocatedContainer(owners, "class0:8f3a2c1d9e4b7a60")
+			Expect(containerID).To(Equal(owners[2].ContainerID))
+
+			// the foreign container has a lower PID than the allocated one
+			foreign := rdtcat.ForeignTasks(owners, containerID)
+			Expect(foreign).To(ConsistOf(HaveField("PID", "1"), HaveField("PID", "2")))
+			Expect(rdtcat.ForeignTasks(owners[2:], containerID)).To(BeEmpty())
+			Expect(rdtcat.ForeignTasks(owners[:1], "")).To(HaveLen(1))
+		})
+
+		It("should find no container for other or missing allocations", func() {
+			owners, err := resctrl.ResolveTasks(snapshotRoot)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(rdtcat.AllocatedContainer(owners, "class0:0000000000000000")).To(BeEmpty())
+			Expect(rdtcat.AllocatedContainer(owners, "")).To(BeEmpty())
+			Expect(rdtcat.ForeignTasks(owners, "")).To(HaveLen(3))
+		})
+	})
+})
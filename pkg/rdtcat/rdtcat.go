@@ -11,20 +11,68 @@ package rdtcat
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
-	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
-// Buffers keeps all infos of configured classes within /sys/fs/resctrl
-// as well as labels for the device plugin.
+// Buffers keeps all infos of configured classes within /sys/fs/resctrl,
+// the capabilities of the utilized caches as well as labels for the device
+// plugin.
 type Buffers struct {
 	Resctrl
-	DpL2Label string
-	DpL3Label string
+	Capabilities   Capabilities
+	DpL2Label      string
+	DpL3Label      string
+	DpL2Labels     LevelLabels
+	DpL3Labels     LevelLabels
+	DpL2L3Labels   L2L3Labels
+	Topology       *Topology      // CPU cache hierarchy and NUMA nodes read with the buffers
+	SizeMismatches []SizeMismatch // differences between reported and derived sizes
+}
+
+// LevelLabels keeps additional labels of one cache level for the device plugin.
+// Empty labels are not applicable.
+type LevelLabels struct {
+	Free   string // number of exclusive buffers that can still be configured
+	Max    string // biggest size configured for any buffer on any cache ID in KiB
+	Code   string // smallest size of the code partitions in KiB (CDP only)
+	Data   string // smallest size of the data partitions in KiB (CDP only)
+	Mb     string // smallest memory bandwidth allocated to any buffer (MBA only)
+	Locked string // smallest size of the pseudo-locked regions in KiB
+}
+
+// L2L3Labels keeps the labels of buffers allocating both, L2 and L3 cache, for
+// the device plugin. The labels are empty if no such buffers are configured.
+type L2L3Labels struct {
+	L2 string // smallest L2 size of the buffers in KiB
+	L3 string // smallest L3 size of the buffers in KiB
+	Mb string // smallest memory bandwidth allocated to any buffer (MBA only)
+}
+
+// NewBuffers returns Buffers that read the resctrl tree located at root.
+func NewBuffers(root string) *Buffers {
+	return NewBuffersFS(root, nil)
+}
+
+// NewBuffersFS returns Buffers that read the resctrl tree through fsys,
+// which has to be rooted at root.
+func NewBuffersFS(root string, fsys fs.FS) *Buffers {
+	b := &Buffers{
+		Resctrl: Resctrl{
+			Root: root,
+			FS:   fsys,
+		},
+	}
+	b.ExcatBuffers = &b.Resctrl
+
+	return b
 }
 
 // InitLogger initializes the logger.
@@ -37,12 +85,14 @@ func InitLogger(ll zerolog.Level) {
 
 // GetAllBuffers reads in all configured buffers in /sys/fs/resctrl.
 // First all buffer details are read and then buffer sizes and cache levels are
-// extracted out of the schema files.
+// extracted out of the schema files. If no class besides the default class is
+// configured, the default class is read in nevertheless and ErrNoClasses is
+// returned.
 func (r *Buffers) GetAllBuffers() error {
 	// get available buffers
-	log.Debug().Msg("Reading from /sys/fs/resctrl")
+	log.Debug().Msgf("Reading from %v", r.GetRoot())
 	if err := r.readFromFs(); err != nil { //nolint:wsl // ok to cuddle debug message
-		return fmt.Errorf("error when reading buffers from /sys/fs/resctrl. Details: %w", err)
+		return fmt.Errorf("error when reading buffers from %v. Details: %w", r.GetRoot(), err)
 	}
 
 	// extract buffer sizes and cache level
@@ -51,126 +101,499 @@ func (r *Buffers) GetAllBuffers() error {
 		return fmt.Errorf("error when extracting buffers sizes and cache level. Details: %w", err)
 	}
 
+	// read capabilities of utilized cache levels
+	log.Debug().Msg("Reading cache capabilities")
+	if err := r.readCapabilities(); err != nil { //nolint:wsl // ok to cuddle debug message
+		return fmt.Errorf("error when reading cache capabilities. Details: %w", err)
+	}
+
+	// the default class always exists
+	if !r.HasClasses() {
+		return fmt.Errorf("%w besides the default class in %v", ErrNoClasses, r.GetRoot())
+	}
+
 	return nil
 }
 
-// ExtractBuffers extracts buffers that utilize cache of a given cache level.
-func (r *Buffers) ExtractBuffers(cacheLevel int) *Buffers {
-	log.Debug().Msgf("Filter buffers based on cacheLevel = %v.", cacheLevel)
-	filter := fmt.Sprintf("L%v", cacheLevel)
-	buffers := Buffers{}
+// ExtractBuffers extracts buffers that utilize cache of exactly the given
+// cache levels. For several cache levels, e.g. 2 and 3, the buffers allocating
+// all of these cache levels are extracted.
+func (r *Buffers) ExtractBuffers(cacheLevels ...int) *Buffers {
+	filter := JoinCacheLevels(cacheLevels...)
+	log.Debug().Msgf("Filter buffers based on cacheLevel = %v.", filter)
+
+	buffers := Buffers{
+		Capabilities: r.Capabilities,
+	}
+
+	for _, group := range r.ResctrlGroups {
+		if group.CacheLevel == filter && !group.IsPseudoLocked() {
+			buffers.ResctrlGroups = append(buffers.ResctrlGroups, group)
+		}
+	}
+
+	return &buffers
+}
+
+// ExtractLockedBuffers returns the pseudo-locked buffers of the given cache
+// level. Buffers still in pseudo-locksetup mode are skipped.
+func (r *Buffers) ExtractLockedBuffers(cacheLevel int) *Buffers {
+	filter := JoinCacheLevels(cacheLevel)
+	log.Debug().Msgf("Filter pseudo-locked buffers based on cacheLevel = %v.", filter)
+
+	buffers := Buffers{
+		Capabilities: r.Capabilities,
+	}
 
 	for _, group := range r.ResctrlGroups {
-		if group.CacheLevel == filter {
-			buffers.ResctrlGroups = append(buffers.ResctrlGroups, ResctrlGroup{
-				Name:         group.Name,
-				Path:         group.Path,
-				BmSchemata:   group.BmSchemata,
-				SizeSchemata: group.SizeSchemata,
-				SizeKib:      group.SizeKib,
-				CacheLevel:   group.CacheLevel,
-			})
+		if group.CacheLevel == filter && group.Mode == ModePseudoLocked {
+			buffers.ResctrlGroups = append(buffers.ResctrlGroups, group)
 		}
 	}
 
 	return &buffers
 }
 
-// extractBufferDetails extracts buffer types (cache level) and sizes in KiB.
-// Ensures that all cache IDs are configured the same so that all CPUs can
-// access all buffers.
+// HasClasses returns true if any class besides the default class is read in.
+// The default class is never advertised as a buffer.
+func (r *Buffers) HasClasses() bool {
+	for _, group := range r.ResctrlGroups {
+		if group.Name != DefaultClass {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KeepMode removes all classes except the default class whose mode differs
+// from the given modes. Capabilities are not recomputed, i.e. removed classes
+// still count as used.
+func (r *Buffers) KeepMode(modes ...string) {
+	groups := make([]ResctrlGroup, 0, len(r.ResctrlGroups))
+
+	for _, group := range r.ResctrlGroups {
+		if group.Name == DefaultClass || slices.Contains(modes, group.Mode) {
+			groups = append(groups, group)
+
+			continue
+		}
+
+		log.Debug().Msgf("Skip %v in mode %v.", group.Name, group.Mode)
+	}
+
+	r.ResctrlGroups = groups
+}
+
+// extractBufferDetails extracts buffer types (cache level) as well as bitmask,
+// size in KiB, sharing CPUs and NUMA nodes for each cache ID. Sizes are
+// checked against the bitmasks, or derived from them if no size file is
+// provided. If cache IDs are configured with different sizes, the smallest
+// size is used as size of the buffer.
 func (r *Buffers) extractBufferDetails() error {
+	topology, err := r.ReadTopology()
+	if err != nil {
+		return err
+	}
+
+	r.Topology = topology
+	r.SizeMismatches = nil
+	cbmBits := map[string]int{}
+
 	for ind, group := range r.ResctrlGroups {
-		var sizes []string
+		// classes in pseudo-locksetup mode have no schemata yet
+		if group.Mode == ModePseudoLockSetup {
+			continue
+		}
+
+		if err := r.ResctrlGroups[ind].extractCacheIDs(topology); err != nil {
+			return err
+		}
+
+		if err := r.checkSizes(&r.ResctrlGroups[ind], cbmBits); err != nil {
+			return err
+		}
+
+		if err := r.ResctrlGroups[ind].extractMbaIDs(); err != nil {
+			return err
+		}
+
+		for _, cacheLevel := range r.ResctrlGroups[ind].CacheLevels() {
+			size := r.ResctrlGroups[ind].LevelSizeKib(cacheLevel)
+			maxSize := r.ResctrlGroups[ind].LevelMaxSizeKib(cacheLevel)
+
+			if maxSize != size {
+				log.Info().Msgf("Different buffer sizes (%v KiB and %v KiB) detected for %v on cache Level %v. "+
+					"The smallest size is advertized as size of the buffer. "+
+					"One root cause can be if TCC is used and SW RAM buffer is allocated on one of the Level %v buffers.",
+					size, maxSize, group.Name, cacheLevel, cacheLevel)
+			}
+		}
+	}
+
+	return nil
+}
+
+// extractCacheIDs extracts cache levels, bitmask and size of each cache ID out
+// of the schemata. With CDP, the bitmasks and sizes of the code and data
+// partition are extracted separately and are combined for the cache ID.
+func (g *ResctrlGroup) extractCacheIDs(topology *Topology) error {
+	// split into cache Level and size info per cache ID
+	sizes := map[string][]cacheIDValue{}
+
+	var sizeLines []string
+	if !g.SizeDerived {
+		sizeLines = strings.Split(g.SizeSchemata, "\n")
+	}
+
+	for _, line := range sizeLines {
+		resource, values, err := parseSchemata(line)
+		if err != nil {
+			return fmt.Errorf("error in %v: %w", g.Path, err)
+		}
+
+		sizes[resource] = values
+	}
+
+	g.CacheIDs = nil
+	cdpTypes := map[string]map[string]bool{} // CDP types per cache level
 
-		// split into cache Level and size info
-		const sizeSchemataParts = 2
+	for _, line := range strings.Split(g.BmSchemata, "\n") {
+		resource, masks, err := parseSchemata(line)
+		if err != nil {
+			return fmt.Errorf("error in %v: %w", g.Path, err)
+		}
+
+		cacheLevel, cdpType := splitCatResource(resource)
+		if cdpTypes[cacheLevel] == nil {
+			cdpTypes[cacheLevel] = map[string]bool{}
+		}
+
+		cdpTypes[cacheLevel][cdpType] = true
+
+		// without size file, the sizes are derived from the bitmasks later on
+		if g.SizeDerived {
+			sizes[resource] = make([]cacheIDValue, len(masks))
+			for ind, mask := range masks {
+				sizes[resource][ind] = cacheIDValue{id: mask.id, value: "0"}
+			}
+		}
+
+		if len(sizes[resource]) == 0 {
+			return fmt.Errorf("%w: missing size info for %v in %v", ErrSchemataFormat, resource, g.Path)
+		}
 
-		sizeSchemataSplit := strings.Split(group.SizeSchemata, ":")
-		if len(sizeSchemataSplit) != sizeSchemataParts {
-			return fmt.Errorf("format error in %v", group.Path)
+		if len(masks) != len(sizes[resource]) {
+			return fmt.Errorf("%w: bitmask and size schemata of %v cover different cache IDs", ErrSchemataFormat, g.Path)
 		}
 
-		r.ResctrlGroups[ind].CacheLevel = sizeSchemataSplit[0]
+		for currentID, size := range sizes[resource] {
+			if masks[currentID].id != size.id {
+				return fmt.Errorf("%w: bitmask and size schemata of %v cover different cache IDs",
+					ErrSchemataFormat, g.Path)
+			}
 
-		// split cache IDs
-		ids := strings.Split(sizeSchemataSplit[1], ";")
+			// get sizes in KiB
+			var sizeBytes int
+			if _, err := fmt.Sscanf(size.value, "%d", &sizeBytes); err != nil {
+				return fmt.Errorf("%w: error when casting size string to int. Details: %w", ErrSchemataFormat, err)
+			}
 
-		// extract sizes and ensure all cache IDs have been configured in the same way
-		sizeReg := regexp.MustCompile(`\d+$`)
-		for currentID := 0; currentID < len(ids); currentID++ {
-			sizes = append(sizes, sizeReg.FindString(ids[currentID]))
-			if currentID > 0 {
-				if sizes[currentID] != sizes[currentID-1] {
-					log.Info().Msgf("Different buffer sizes (%v and %v) detected for cache Level %v."+
-						"Smallest size is used for all ExCAT buffers and thus the size difference to the bigger buffer is wasted. "+
-						"One root cause can be if TCC is used and SW RAM buffer is allocated on one of the Level %v buffers.",
-						sizes[currentID], sizes[currentID-1], r.ResctrlGroups[ind].CacheLevel, r.ResctrlGroups[ind].CacheLevel)
-				}
+			mask, err := parseBitmask(masks[currentID].value)
+			if err != nil {
+				return fmt.Errorf("%w in %v: %w", ErrSchemataFormat, g.Path, err)
+			}
+
+			cacheID := g.getOrAddCacheID(cacheLevel, size.id)
+			cacheID.SharedCPUs = topology.CPUs(cacheLevel, size.id)
+			cacheID.NumaNodes = topology.NumaNodes(cacheLevel, size.id)
+
+			switch cdpType {
+			case CdpCode:
+				cacheID.CodeMask = mask
+				cacheID.CodeSizeKib = bytes2kib(sizeBytes)
+			case CdpData:
+				cacheID.DataMask = mask
+				cacheID.DataSizeKib = bytes2kib(sizeBytes)
+			default:
+				cacheID.Mask = mask
+				cacheID.SizeKib = bytes2kib(sizeBytes)
 			}
 		}
+	}
+
+	cacheLevels := make([]string, 0, len(cdpTypes))
+	g.Cdp = false
 
-		if len(sizes) == 0 {
-			return fmt.Errorf("missing size info in %v", group.Path)
+	for cacheLevel, types := range cdpTypes {
+		cacheLevels = append(cacheLevels, cacheLevel)
+
+		if !types[CdpCode] && !types[CdpData] {
+			continue
 		}
 
-		// get sizes in KiB
-		var sizeBytes int
-		if _, err := fmt.Sscanf(sizes[0], "%d", &sizeBytes); err != nil {
-			return fmt.Errorf("error when casting size string to int. Details: %w", err)
+		if !types[CdpCode] || !types[CdpData] {
+			return fmt.Errorf("%w: incomplete CDP definition of %v in %v: code and data partition required",
+				ErrSchemataFormat, cacheLevel, g.Path)
 		}
 
-		r.ResctrlGroups[ind].SizeKib = bytes2kib(sizeBytes)
+		// a CDP buffer consists of the code and the data partition
+		g.Cdp = true
+
+		for ind := range g.CacheIDs {
+			if g.CacheIDs[ind].CacheLevel == cacheLevel {
+				g.CacheIDs[ind].Mask = g.CacheIDs[ind].CodeMask | g.CacheIDs[ind].DataMask
+				g.CacheIDs[ind].SizeKib = g.CacheIDs[ind].CodeSizeKib + g.CacheIDs[ind].DataSizeKib
+			}
+		}
 	}
 
+	sort.Strings(cacheLevels)
+	g.CacheLevel = strings.Join(cacheLevels, CacheLevelSep)
+	g.SizeKib = g.MinSizeKib()
+
 	return nil
 }
 
+// extractMbaIDs extracts the memory bandwidth of each MBA domain out of the
+// MBA schemata.
+func (g *ResctrlGroup) extractMbaIDs() error {
+	g.MbaIDs = nil
+
+	if g.MbSchemata == "" {
+		return nil
+	}
+
+	resource, values, err := parseSchemata(g.MbSchemata)
+	if err != nil {
+		return fmt.Errorf("error in %v: %w", g.Path, err)
+	}
+
+	if resource != MbResource {
+		return fmt.Errorf("%w: unknown MBA resource %v in %v", ErrSchemataFormat, resource, g.Path)
+	}
+
+	for _, value := range values {
+		bandwidth, err := strconv.Atoi(value.value)
+		if err != nil {
+			return fmt.Errorf("%w: invalid memory bandwidth in %v: %w", ErrSchemataFormat, g.Path, err)
+		}
+
+		g.MbaIDs = append(g.MbaIDs, MbaID{ID: value.id, Bandwidth: bandwidth})
+	}
+
+	return nil
+}
+
+// getOrAddCacheID returns the cache ID with the given ID on the given cache
+// level and adds it if it doesn't exist yet.
+func (g *ResctrlGroup) getOrAddCacheID(cacheLevel string, id int) *CacheID {
+	for ind := range g.CacheIDs {
+		if g.CacheIDs[ind].CacheLevel == cacheLevel && g.CacheIDs[ind].ID == id {
+			return &g.CacheIDs[ind]
+		}
+	}
+
+	g.CacheIDs = append(g.CacheIDs, CacheID{ID: id, CacheLevel: cacheLevel})
+
+	return &g.CacheIDs[len(g.CacheIDs)-1]
+}
+
 // CreateLabels creates labels to be used with the device plugin.
 // Based on the labels, worker nodes can be patched to provide the size info
 // in addition to the extended resources advertized by the device plugin.
-// Only one label is created for L2 and L3 cache, respectively. Classes must
-// only configure either L2 or L3 cache. If buffers for one cache level have
-// different sizes, the smallest size is advertized.
+// Only one label is created for L2 and L3 cache, respectively. If buffers for
+// one cache level have different sizes, the smallest size is advertized.
+// Additional labels per cache level are kept in DpL2Labels and DpL3Labels.
+// Buffers allocating both, L2 and L3 cache, are labelled separately with
+// both sizes in DpL2L3Labels.
 func (r *Buffers) CreateLabels() error {
-	var prevL2Size, prevL3Size int = -1, -1
+	r.DpL2Label, r.DpL2Labels = r.createLevelLabels(2) //nolint:gomnd // cache level 2
+	r.DpL3Label, r.DpL3Labels = r.createLevelLabels(3) //nolint:gomnd // cache level 3
+	r.DpL2L3Labels = r.createL2L3Labels()
+
+	if r.DpL2Label == "" && r.DpL3Label == "" && r.DpL2L3Labels.L2 == "" &&
+		r.DpL2Labels.Locked == "" && r.DpL3Labels.Locked == "" {
+		return fmt.Errorf("no labels were created: %w: configure resctrl and read in buffers first", ErrNoClasses)
+	}
+
+	log.Debug().Msgf("L2 Label: %v, additional labels: %+v", r.DpL2Label, r.DpL2Labels)
+	log.Debug().Msgf("L3 Label: %v, additional labels: %+v", r.DpL3Label, r.DpL3Labels)
+	log.Debug().Msgf("L2+L3 Labels: %+v", r.DpL2L3Labels)
+
+	return nil
+}
+
+// createL2L3Labels creates the labels of buffers allocating both, L2 and L3
+// cache, with the smallest size of each cache level.
+func (r *Buffers) createL2L3Labels() L2L3Labels {
+	var (
+		labels       L2L3Labels
+		minL2, minL3 = -1, -1
+		minBandwidth = newMinBandwidth()
+		filter       = JoinCacheLevels(2, 3) //nolint:gomnd // cache level 2 and 3
+	)
 
-	// Create single device plugin label for L2 and L3 with size in KiB, respectively.
 	for _, group := range r.ResctrlGroups {
-		if group.Name != DefaultClass {
-			switch group.CacheLevel {
-			case "L2":
-				if prevL2Size == -1 {
-					r.DpL2Label = fmt.Sprintf("%v", group.SizeKib)
-				} else if group.SizeKib < prevL2Size {
-					r.DpL2Label = fmt.Sprintf("%v", group.SizeKib)
-				}
-
-				prevL2Size = group.SizeKib
-
-			case "L3":
-				if prevL3Size == -1 {
-					r.DpL3Label = fmt.Sprintf("%v", group.SizeKib)
-				} else if group.SizeKib < prevL3Size {
-					r.DpL3Label = fmt.Sprintf("%v", group.SizeKib)
-				}
-
-				prevL3Size = group.SizeKib
+		if group.Name == DefaultClass || group.CacheLevel != filter || group.IsPseudoLocked() {
+			continue
+		}
+
+		if size := group.LevelSizeKib("L2"); minL2 == -1 || size < minL2 {
+			minL2 = size
+		}
+
+		if size := group.LevelSizeKib("L3"); minL3 == -1 || size < minL3 {
+			minL3 = size
+		}
+
+		minBandwidth.add(group)
+	}
+
+	if minL2 != -1 {
+		labels.L2 = fmt.Sprintf("%v", minL2)
+		labels.L3 = fmt.Sprintf("%v", minL3)
+		labels.Mb = minBandwidth.label()
+	}
+
+	return labels
+}
+
+// createLevelLabels creates the size label and the additional labels of one
+// cache level. The size label is empty if no buffers use the cache level.
+// Pseudo-locked regions are labelled separately.
+func (r *Buffers) createLevelLabels(cacheLevel int) (string, LevelLabels) {
+	var (
+		labels                                       LevelLabels
+		minSize, minCodeSize, minDataSize, minLocked = -1, -1, -1, -1
+		maxSize                                      int
+		minBandwidth                                 = newMinBandwidth()
+	)
+
+	filter := fmt.Sprintf("L%v", cacheLevel)
+
+	for _, group := range r.ResctrlGroups {
+		if group.Name == DefaultClass || group.CacheLevel != filter {
+			continue
+		}
+
+		if group.IsPseudoLocked() {
+			if region, ok := group.LockedRegion(); ok && (minLocked == -1 || region.SizeKib < minLocked) {
+				minLocked = region.SizeKib
 			}
+
+			continue
+		}
+
+		if minSize == -1 || group.SizeKib < minSize {
+			minSize = group.SizeKib
 		}
+
+		if size := group.MaxSizeKib(); size > maxSize {
+			maxSize = size
+		}
+
+		minBandwidth.add(group)
+
+		if group.Cdp {
+			if minCodeSize == -1 || group.CodeSizeKib() < minCodeSize {
+				minCodeSize = group.CodeSizeKib()
+			}
+
+			if minDataSize == -1 || group.DataSizeKib() < minDataSize {
+				minDataSize = group.DataSizeKib()
+			}
+		}
+	}
+
+	if minLocked != -1 {
+		labels.Locked = fmt.Sprintf("%v", minLocked)
 	}
 
-	if r.DpL2Label == "" && r.DpL3Label == "" {
-		return fmt.Errorf("no labels were created: configure resctrl and read in buffers first")
+	if minSize == -1 {
+		return "", labels
 	}
 
-	log.Debug().Msgf("L2 Label: %v", r.DpL2Label)
-	log.Debug().Msgf("L3 Label: %v", r.DpL3Label)
+	if caps, ok := r.Capabilities.Get(cacheLevel); ok {
+		labels.Free = fmt.Sprintf("%v", caps.FreeBuffers)
+	}
 
-	return nil
+	if maxSize > 0 {
+		labels.Max = fmt.Sprintf("%v", maxSize)
+	}
+
+	if minCodeSize != -1 {
+		labels.Code = fmt.Sprintf("%v", minCodeSize)
+		labels.Data = fmt.Sprintf("%v", minDataSize)
+	}
+
+	labels.Mb = minBandwidth.label()
+
+	return fmt.Sprintf("%v", minSize), labels
+}
+
+// minBandwidth keeps the smallest memory bandwidth allocated to a set of
+// buffers.
+type minBandwidth struct {
+	bandwidth int
+}
+
+// newMinBandwidth returns a minBandwidth without any buffer added.
+func newMinBandwidth() *minBandwidth {
+	return &minBandwidth{bandwidth: -1}
+}
+
+// add adds the memory bandwidth of a buffer. Buffers without MBA are ignored.
+func (m *minBandwidth) add(group ResctrlGroup) {
+	if len(group.MbaIDs) == 0 {
+		return
+	}
+
+	if bandwidth := group.MinBandwidth(); m.bandwidth == -1 || bandwidth < m.bandwidth {
+		m.bandwidth = bandwidth
+	}
+}
+
+// label returns the smallest memory bandwidth as label, empty if no buffer
+// with MBA was added.
+func (m *minBandwidth) label() string {
+	if m.bandwidth == -1 {
+		return ""
+	}
+
+	return fmt.Sprintf("%v", m.bandwidth)
+}
+
+// LockedRegions returns the regions of all pseudo-locked classes.
+func (r *Buffers) LockedRegions() []LockedRegion {
+	var regions []LockedRegion
+
+	for _, group := range r.ResctrlGroups {
+		if region, ok := group.LockedRegion(); ok {
+			regions = append(regions, region)
+		}
+	}
+
+	return regions
+}
+
+// IsCdp returns true if any buffer allocates code and data separately.
+func (r *Buffers) IsCdp() bool {
+	for _, group := range r.ResctrlGroups {
+		if group.Cdp {
+			return true
+		}
+	}
+
+	return false
 }
 
 // bytes2kib converts bytes into kibibytes (KiB).
 func bytes2kib(b int) int {
 	return (b / 1024) //nolint:gomnd // no magic number in this case
 }
+
+// kib2bytes converts kibibytes (KiB) into bytes.
+func kib2bytes(kib int) int {
+	return (kib * 1024) //nolint:gomnd // no magic number in this case
+}
@@ -5,6 +5,7 @@ package rdtcat_test
 
 import (
 	"path"
+	"testing/fstest"
 
 	"github.com/csl-svc/excat/pkg/mocks"
 	"github.com/csl-svc/excat/pkg/rdtcat"
@@ -36,6 +37,8 @@ var _ = Describe("Rdtcat", func() {
 		SizeKibDefault      int
 		DpL2Label           string
 		DpL3Label           string
+		PathInfoL3          string
+		CapabilitiesL3      rdtcat.CacheCapabilities
 		tasks               []string
 		ClassNames          []string
 		path2tasks          string
@@ -58,6 +61,7 @@ var _ = Describe("Rdtcat", func() {
 			// dummy input values (usually read from /sys/fs/resctrl)
 			resctrl = &rdtcat.Resctrl{
 				ExcatBuffers: mockExcatBuffers,
+				SysFS:        fstest.MapFS{},
 			}
 			rdtcatBuffers = &rdtcat.Buffers{
 				Resctrl: *resctrl,
@@ -80,6 +84,20 @@ var _ = Describe("Rdtcat", func() {
 			SizeKibClass0 = 2560
 			SizeKibClass1 = 3840
 			SizeKibDefault = 5120
+			PathInfoL3 = rdtcat.RdtctrlPath + "/info/L3"
+			CapabilitiesL3 = rdtcat.CacheCapabilities{
+				CacheLevel:    "L3",
+				NumClosids:    16,
+				CbmMask:       0xfffff,
+				CbmBits:       20,
+				MinCbmBits:    1,
+				ShareableBits: 0,
+				BitUsage:      "0=SSSS00000000000SSSSS",
+				BytesPerBit:   1310720,
+				FreeClosids:   13,
+				FreeBits:      15,
+				FreeBuffers:   13,
+			}
 
 			// mock expectations
 			mockExcatBuffers.EXPECT().GetClassNames().Return(ClassNames, nil).Times(1)
@@ -95,6 +113,22 @@ var _ = Describe("Rdtcat", func() {
 				Return(SizeSchemataClass0, nil).Times(1)
 			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathClass1, "size"), true).
 				Return(SizeSchemataClass1, nil).Times(1)
+			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathDefault, "mode"), false).
+				Return([]string{rdtcat.ModeShareable}, nil).Times(1)
+			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathClass0, "mode"), false).
+				Return([]string{rdtcat.ModeExclusive}, nil).Times(1)
+			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathClass1, "mode"), false).
+				Return([]string{rdtcat.ModeShareable}, nil).Times(1)
+			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathInfoL3, "num_closids"), false).
+				Return([]string{"16"}, nil).Times(1)
+			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathInfoL3, "cbm_mask"), false).
+				Return([]string{"fffff"}, nil).Times(1)
+			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathInfoL3, "min_cbm_bits"), false).
+				Return([]string{"1"}, nil).Times(1)
+			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathInfoL3, "shareable_bits"), false).
+				Return([]string{"0"}, nil).Times(1)
+			mockExcatBuffers.EXPECT().ReadFile(path.Join(PathInfoL3, "bit_usage"), false).
+				Return([]string{"0=SSSS00000000000SSSSS"}, nil).Times(1)
 		})
 
 		It("should collect schemata and extract cache level and size in KiB", func() {
@@ -112,8 +146,15 @@ var _ = Describe("Rdtcat", func() {
 			Expect(rdtcatBuffers.Resctrl.ResctrlGroups[1].CacheLevel).To(Equal(CacheLevelClass1))
 			Expect(rdtcatBuffers.Resctrl.ResctrlGroups[2].CacheLevel).To(Equal(CacheLevelDefault))
 			Expect(rdtcatBuffers.Resctrl.ResctrlGroups[0].SizeKib).To(Equal(SizeKibClass0))
+			Expect(rdtcatBuffers.Resctrl.ResctrlGroups[0].Mode).To(Equal(rdtcat.ModeExclusive))
+			Expect(rdtcatBuffers.Resctrl.ResctrlGroups[1].Mode).To(Equal(rdtcat.ModeShareable))
 			Expect(rdtcatBuffers.Resctrl.ResctrlGroups[1].SizeKib).To(Equal(SizeKibClass1))
 			Expect(rdtcatBuffers.Resctrl.ResctrlGroups[2].SizeKib).To(Equal(SizeKibDefault))
+			Expect(rdtcatBuffers.Resctrl.ResctrlGroups[0].CacheIDs).To(Equal([]rdtcat.CacheID{
+				{ID: 0, CacheLevel: "L3", Mask: 0x00003, SizeKib: SizeKibClass0},
+			}))
+			Expect(rdtcatBuffers.Capabilities).To(HaveLen(1))
+			Expect(rdtcatBuffers.Capabilities["L3"]).To(Equal(CapabilitiesL3))
 		})
 	})
 
@@ -11,13 +11,16 @@ package rdtcat
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
-	"github.com/intel/goresctrl/pkg/rdt"
 	"github.com/rs/zerolog/log"
 )
 
@@ -27,19 +30,289 @@ const (
 )
 
 // Resctrl keeps all info collected from the configured classes in /sys/fs/resctl.
+// Root and FS allow to read from a different location than RdtctrlPath, e.g.
+// from a snapshot of the resctrl tree captured on another node. The same
+// applies to SysRoot and SysFS for the CPU cache hierarchy in sysfs and to
+// ProcRoot and ProcFS for the cgroups of tasks in procfs.
 type Resctrl struct {
 	ExcatBuffers
 	ResctrlGroups []ResctrlGroup
+	Root          string // resctrl root directory, RdtctrlPath if empty
+	FS            fs.FS  // filesystem rooted at Root, os.DirFS(Root) if nil
+	SysRoot       string // sysfs root directory, SysfsPath if empty
+	SysFS         fs.FS  // filesystem rooted at SysRoot, os.DirFS(SysRoot) if nil
+	ProcRoot      string // procfs root directory, ProcfsPath if empty
+	ProcFS        fs.FS  // filesystem rooted at ProcRoot, os.DirFS(ProcRoot) if nil
+	localFS       bool   // FS was created from Root and writes go to the same tree
 }
 
 // ResctrlGroup keeps all info for one configured class in /sys/fs/resctrl.
+// SizeKib is the smallest size configured on any cache ID.
+// With Code and Data Prioritization (CDP), the schemata contain one line for
+// the code and one line for the data partition, separated by a newline. The
+// same applies to classes allocating several cache levels, whose CacheLevel
+// joins all cache levels with CacheLevelSep, e.g. "L2+L3".
+// MbSchemata keeps the memory bandwidth allocation (MBA) line of the schemata,
+// which is empty if MBA is not supported. Mode is empty if the kernel doesn't
+// provide a mode file.
 type ResctrlGroup struct {
 	Name         string
 	Path         string
 	BmSchemata   string
 	SizeSchemata string
+	MbSchemata   string
+	Mode         string
 	SizeKib      int
 	CacheLevel   string
+	CacheIDs     []CacheID
+	Cdp          bool // code and data are allocated separately
+	MbaIDs       []MbaID
+	SizeDerived  bool // sizes derived from the bitmasks since no size file is provided
+}
+
+// MbaID keeps the memory bandwidth allocated to a class on one MBA domain.
+// Bandwidth is the throttle value as configured in the schemata, i.e. in
+// percent or, if resctrl is mounted with mba_MBps, in MBps.
+type MbaID struct {
+	ID        int
+	Bandwidth int
+}
+
+// CacheID keeps the part of a class that is allocated on one cache ID of one
+// cache level. With CDP, Mask and SizeKib cover both, the code and data
+// partition.
+type CacheID struct {
+	ID          int
+	CacheLevel  string
+	Mask        uint64 // capacity bitmask
+	SizeKib     int
+	CodeMask    uint64 // capacity bitmask of the code partition (CDP only)
+	CodeSizeKib int    // size of the code partition (CDP only)
+	DataMask    uint64 // capacity bitmask of the data partition (CDP only)
+	DataSizeKib int    // size of the data partition (CDP only)
+	SharedCPUs  []int  // CPUs sharing the cache, empty if unknown
+	NumaNodes   []int  // NUMA nodes of the CPUs sharing the cache, empty if unknown
+}
+
+// CDP types of a cache allocation as used in the schemata
+const (
+	CdpCode = "CODE"
+	CdpData = "DATA"
+)
+
+// Modes of a class as reported in its mode file
+const (
+	ModeShareable       = "shareable"        // allocations may be shared with other classes
+	ModeExclusive       = "exclusive"        // allocations are not shared with any other class
+	ModePseudoLockSetup = "pseudo-locksetup" // class is about to be pseudo-locked
+	ModePseudoLocked    = "pseudo-locked"    // allocations are pseudo-locked
+)
+
+// PseudoLockDevPath is the directory of the character devices providing
+// access to the memory of pseudo-locked regions.
+const PseudoLockDevPath = "/dev/pseudo_lock"
+
+// LockedRegion keeps the cache region pseudo-locked by a class. The memory
+// of the region can be mapped through Device.
+type LockedRegion struct {
+	Class      string
+	CacheLevel string
+	CacheID    int
+	Mask       uint64
+	SizeKib    int
+	SharedCPUs []int // CPUs with low latency access to the region
+	Device     string
+}
+
+// IsPseudoLocked returns true if the class is pseudo-locked or about to be
+// pseudo-locked. Tasks can't be assigned to such classes.
+func (g *ResctrlGroup) IsPseudoLocked() bool {
+	return g.Mode == ModePseudoLocked || g.Mode == ModePseudoLockSetup
+}
+
+// LockedRegion returns the region pseudo-locked by the class. A class
+// pseudo-locks a region on one cache ID only.
+func (g *ResctrlGroup) LockedRegion() (LockedRegion, bool) {
+	if g.Mode != ModePseudoLocked || len(g.CacheIDs) != 1 {
+		return LockedRegion{}, false
+	}
+
+	cacheID := g.CacheIDs[0]
+
+	return LockedRegion{
+		Class:      g.Name,
+		CacheLevel: g.CacheLevel,
+		CacheID:    cacheID.ID,
+		Mask:       cacheID.Mask,
+		SizeKib:    cacheID.SizeKib,
+		SharedCPUs: cacheID.SharedCPUs,
+		Device:     path.Join(PseudoLockDevPath, g.Name),
+	}, true
+}
+
+// MbResource is the resource name of memory bandwidth allocation (MBA) in the
+// schemata.
+const MbResource = "MB"
+
+// CacheLevelSep separates the cache levels of classes allocating several cache
+// levels.
+const CacheLevelSep = "+"
+
+// JoinCacheLevels returns the cache level of classes allocating all given
+// cache levels, e.g. "L2+L3" for 2 and 3.
+func JoinCacheLevels(cacheLevels ...int) string {
+	levels := make([]string, len(cacheLevels))
+	for ind, level := range cacheLevels {
+		levels[ind] = fmt.Sprintf("L%v", level)
+	}
+
+	sort.Strings(levels)
+
+	return strings.Join(levels, CacheLevelSep)
+}
+
+// CacheLevels returns all cache levels allocated by the class.
+func (g *ResctrlGroup) CacheLevels() []string {
+	if g.CacheLevel == "" {
+		return nil
+	}
+
+	return strings.Split(g.CacheLevel, CacheLevelSep)
+}
+
+// HasCacheLevel returns true if the class allocates the given cache level.
+func (g *ResctrlGroup) HasCacheLevel(cacheLevel string) bool {
+	for _, level := range g.CacheLevels() {
+		if level == cacheLevel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LevelCacheIDs returns the cache IDs of the class on a given cache level.
+func (g *ResctrlGroup) LevelCacheIDs(cacheLevel string) []CacheID {
+	if g.CacheLevel == cacheLevel {
+		return g.CacheIDs
+	}
+
+	var cacheIDs []CacheID
+
+	for _, cacheID := range g.CacheIDs {
+		if cacheID.CacheLevel == cacheLevel {
+			cacheIDs = append(cacheIDs, cacheID)
+		}
+	}
+
+	return cacheIDs
+}
+
+// LevelSizeKib returns the smallest size configured on any cache ID of a
+// given cache level.
+func (g *ResctrlGroup) LevelSizeKib(cacheLevel string) int {
+	var minSize int
+
+	for ind, cacheID := range g.LevelCacheIDs(cacheLevel) {
+		if ind == 0 || cacheID.SizeKib < minSize {
+			minSize = cacheID.SizeKib
+		}
+	}
+
+	return minSize
+}
+
+// LevelMaxSizeKib returns the biggest size configured on any cache ID of a
+// given cache level.
+func (g *ResctrlGroup) LevelMaxSizeKib(cacheLevel string) int {
+	var size int
+
+	for _, cacheID := range g.LevelCacheIDs(cacheLevel) {
+		if cacheID.SizeKib > size {
+			size = cacheID.SizeKib
+		}
+	}
+
+	return size
+}
+
+// MinBandwidth returns the smallest memory bandwidth allocated on any MBA
+// domain, 0 if MBA is not supported.
+func (g *ResctrlGroup) MinBandwidth() int {
+	var bandwidth int
+
+	for ind, mbaID := range g.MbaIDs {
+		if ind == 0 || mbaID.Bandwidth < bandwidth {
+			bandwidth = mbaID.Bandwidth
+		}
+	}
+
+	return bandwidth
+}
+
+// isCdp returns true if the class allocates code and data of a given cache
+// level separately.
+func (g *ResctrlGroup) isCdp(cacheLevel string) bool {
+	for _, cacheID := range g.LevelCacheIDs(cacheLevel) {
+		if cacheID.CodeMask != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MinSizeKib returns the smallest size configured on any cache ID.
+func (g *ResctrlGroup) MinSizeKib() int {
+	return g.minSizeKib(func(c CacheID) int { return c.SizeKib })
+}
+
+// MaxSizeKib returns the biggest size configured on any cache ID.
+func (g *ResctrlGroup) MaxSizeKib() int {
+	var size int
+
+	for _, cacheID := range g.CacheIDs {
+		if cacheID.SizeKib > size {
+			size = cacheID.SizeKib
+		}
+	}
+
+	return size
+}
+
+// CodeSizeKib returns the smallest size of the code partition on any cache ID.
+func (g *ResctrlGroup) CodeSizeKib() int {
+	return g.minSizeKib(func(c CacheID) int { return c.CodeSizeKib })
+}
+
+// DataSizeKib returns the smallest size of the data partition on any cache ID.
+func (g *ResctrlGroup) DataSizeKib() int {
+	return g.minSizeKib(func(c CacheID) int { return c.DataSizeKib })
+}
+
+// minSizeKib returns the smallest size on any cache ID.
+func (g *ResctrlGroup) minSizeKib(size func(CacheID) int) int {
+	var minSize int
+
+	for ind, cacheID := range g.CacheIDs {
+		if ind == 0 || size(cacheID) < minSize {
+			minSize = size(cacheID)
+		}
+	}
+
+	return minSize
+}
+
+// GetCacheID returns the part of the class allocated on a given cache ID of a
+// given cache level.
+func (g *ResctrlGroup) GetCacheID(cacheLevel string, id int) (CacheID, bool) {
+	for _, cacheID := range g.LevelCacheIDs(cacheLevel) {
+		if cacheID.ID == id {
+			return cacheID, true
+		}
+	}
+
+	return CacheID{}, false
 }
 
 // ExcatBuffers provides an interface that can be implemented by the mock for unit tests
@@ -49,6 +322,58 @@ type ExcatBuffers interface {
 	ReadFile(string, bool) ([]string, error)
 }
 
+// NewResctrl returns a Resctrl that reads the resctrl tree located at root.
+func NewResctrl(root string) *Resctrl {
+	return NewResctrlFS(root, nil)
+}
+
+// NewResctrlFS returns a Resctrl that reads the resctrl tree through fsys.
+// All paths handled by the Resctrl are located below root, fsys has to be
+// rooted at root.
+func NewResctrlFS(root string, fsys fs.FS) *Resctrl {
+	r := &Resctrl{
+		Root: root,
+		FS:   fsys,
+	}
+	r.ExcatBuffers = r
+
+	return r
+}
+
+// GetRoot returns the resctrl root directory.
+func (r *Resctrl) GetRoot() string {
+	if r.Root == "" {
+		return RdtctrlPath
+	}
+
+	return r.Root
+}
+
+// getFS returns the filesystem rooted at the resctrl root directory.
+func (r *Resctrl) getFS() fs.FS {
+	if r.FS == nil {
+		r.FS = os.DirFS(r.GetRoot())
+		r.localFS = true
+	}
+
+	return r.FS
+}
+
+// relPath converts a path below the resctrl root into a path within getFS.
+func (r *Resctrl) relPath(p string) (string, error) {
+	rel, err := filepath.Rel(r.GetRoot(), p)
+	if err != nil {
+		return "", fmt.Errorf("error when resolving %v: %w", p, err)
+	}
+
+	rel = filepath.ToSlash(rel)
+	if !fs.ValidPath(rel) {
+		return "", fmt.Errorf("%v is not located in %v", p, r.GetRoot())
+	}
+
+	return rel, nil
+}
+
 // readFromFs reads configured classes from the resctrl pseudo filesystem.
 // Gets all classes names, bitmask schemata and size schemata files.
 func (r *Resctrl) readFromFs() error {
@@ -58,12 +383,7 @@ func (r *Resctrl) readFromFs() error {
 		return fmt.Errorf("error when reading in class names: %w", err)
 	}
 
-	numClasses := len(names)
-	if numClasses == 0 {
-		return fmt.Errorf("error in readFromFs, no classes detected in /sys/fs/resctrl")
-	}
-
-	r.ResctrlGroups = make([]ResctrlGroup, numClasses)
+	r.ResctrlGroups = make([]ResctrlGroup, len(names))
 
 	// for each class
 	for ind, name := range names {
@@ -72,9 +392,22 @@ func (r *Resctrl) readFromFs() error {
 		// get class paths
 		switch name {
 		case DefaultClass:
-			r.ResctrlGroups[ind].Path = RdtctrlPath
+			r.ResctrlGroups[ind].Path = r.GetRoot()
 		default:
-			r.ResctrlGroups[ind].Path = path.Join(RdtctrlPath, name)
+			r.ResctrlGroups[ind].Path = path.Join(r.GetRoot(), name)
+		}
+
+		// read mode file, which is not provided by older kernels
+		if r.ResctrlGroups[ind].Mode, err = r.readMode(r.ResctrlGroups[ind].Path); err != nil {
+			return fmt.Errorf("error in readFromFs: %w", err)
+		}
+
+		// the schemata of a class in pseudo-locksetup mode is uninitialized
+		// until a region is written to it
+		if r.ResctrlGroups[ind].Mode == ModePseudoLockSetup {
+			log.Debug().Msgf("Skip schemata of %v in mode %v.", name, ModePseudoLockSetup)
+
+			continue
 		}
 
 		// read schemata file
@@ -83,61 +416,172 @@ func (r *Resctrl) readFromFs() error {
 			return fmt.Errorf("error in readFromFs: %w", err)
 		}
 
-		if len(schemata) != 1 {
-			return fmt.Errorf("multiple definitions in Bit Mask Schemata file, just one cache level allowed per buffer")
+		schemata, r.ResctrlGroups[ind].MbSchemata = splitMbSchemata(schemata)
+		if len(schemata) == 0 {
+			return fmt.Errorf("%w: missing definitions in Bit Mask Schemata file of %v",
+				ErrSchemataFormat, r.ResctrlGroups[ind].Path)
 		}
 
-		r.ResctrlGroups[ind].BmSchemata = schemata[0]
+		r.ResctrlGroups[ind].BmSchemata = strings.Join(schemata, "\n")
 
-		// read size file
+		// read size file, which is not provided by older kernels. The sizes are
+		// derived from the bitmasks then.
 		schemata, err = r.ExcatBuffers.ReadFile(path.Join(r.ResctrlGroups[ind].Path, "size"), true)
-		if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Debug().Msgf("No size file in %v, deriving sizes from bitmasks.", r.ResctrlGroups[ind].Path)
+			r.ResctrlGroups[ind].SizeDerived = true
+		} else if err != nil {
 			return fmt.Errorf("error in readFromFs: %w", err)
 		}
 
-		if len(schemata) != 1 {
-			return fmt.Errorf("multiple definitions in Size Schemata file, just one cache level allowed per buffer")
+		// the size file repeats the MBA line of the schemata
+		schemata, _ = splitMbSchemata(schemata)
+		if len(schemata) == 0 && !r.ResctrlGroups[ind].SizeDerived {
+			return fmt.Errorf("%w: missing definitions in Size Schemata file of %v",
+				ErrSchemataFormat, r.ResctrlGroups[ind].Path)
+		}
+
+		r.ResctrlGroups[ind].SizeSchemata = strings.Join(schemata, "\n")
+	}
+
+	return nil
+}
+
+// readMode reads the mode of the class located in the given directory. The
+// mode is empty if the mode file doesn't exist.
+func (r *Resctrl) readMode(classPath string) (string, error) {
+	lines, err := r.ExcatBuffers.ReadFile(path.Join(classPath, "mode"), false)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if len(lines) != 1 {
+		return "", fmt.Errorf("format error in mode file of %v", classPath)
+	}
+
+	return lines[0], nil
+}
+
+// SetMode sets the mode of a class by writing its mode file. Since fs.FS is
+// read-only, the mode file is written in the resctrl root on the local
+// filesystem, which fails with ErrReadOnly if the tree is read through another
+// filesystem, e.g. a snapshot. The mode file has to exist already. The kernel
+// refuses the mode e.g. if an exclusive class shares bits with another class,
+// details are taken from info/last_cmd_status.
+func (r *Resctrl) SetMode(class string, mode string) error {
+	if class == DefaultClass {
+		return fmt.Errorf("mode of %v can't be changed", DefaultClass)
+	}
+
+	if r.FS != nil && !r.localFS {
+		return fmt.Errorf("error when setting mode %v for %v: %w", mode, class, ErrReadOnly)
+	}
+
+	modeFile := path.Join(r.GetRoot(), class, "mode")
+
+	if err := writeExisting(modeFile, []byte(mode+"\n")); err != nil {
+		status, statusErr := r.ExcatBuffers.ReadFile(path.Join(r.GetRoot(), "info", "last_cmd_status"), false)
+		if statusErr == nil && len(status) > 0 {
+			return fmt.Errorf("error when setting mode %v for %v: %w: %v", mode, class, err, strings.Join(status, " "))
 		}
 
-		r.ResctrlGroups[ind].SizeSchemata = schemata[0]
+		return fmt.Errorf("error when setting mode %v for %v: %w", mode, class, err)
 	}
 
+	log.Debug().Msgf("Set mode of %v to %v.", class, mode)
+
 	return nil
 }
 
+// writeExisting writes data to an existing file. Unlike os.WriteFile, it
+// doesn't create missing files, which resctrl only provides itself.
+func writeExisting(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0o644) //nolint:gosec,gomnd // resctrl file
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
+
+// splitMbSchemata splits the lines of a schemata file into the lines
+// allocating cache and the MBA line.
+func splitMbSchemata(lines []string) ([]string, string) {
+	var (
+		catLines []string
+		mbLine   string
+	)
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, MbResource+":") {
+			mbLine = line
+
+			continue
+		}
+
+		catLines = append(catLines, line)
+	}
+
+	return catLines, mbLine
+}
+
 // GetClassNames reads class names of classes configured in /sys/fs/resctrl.
+// Like for goresctrl, every directory in the resctrl root that contains a
+// tasks file is a class. The root directory itself is the default class.
 func (r *Resctrl) GetClassNames() ([]string, error) {
-	// init rdt
-	if err := rdt.Initialize(""); err != nil {
-		return []string{}, fmt.Errorf("RDT not supported: %w", err)
+	fsys := r.getFS()
+
+	// check if resctrl is mounted
+	if _, err := fs.Stat(fsys, "schemata"); err != nil {
+		return []string{}, fmt.Errorf("%w: no resctrl filesystem found at %v: %w", ErrRDTUnsupported, r.GetRoot(), err)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return []string{}, fmt.Errorf("error when reading %v: %w", r.GetRoot(), err)
 	}
 
 	// get class names
-	ctrlgrp := rdt.GetClasses()
+	names := []string{DefaultClass}
 
-	// check if RDT CAT is supported
-	numClasses := len(ctrlgrp)
-	if numClasses == 0 {
-		log.Info().Msgf("RDT CAT not supported or properly configured on this machine.")
-		os.Exit(0)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		info, err := fs.Stat(fsys, path.Join(entry.Name(), "tasks"))
+		if err == nil && !info.IsDir() {
+			names = append(names, entry.Name())
+		}
 	}
 
-	log.Debug().Msgf("Detected %v classes.", numClasses)
+	sort.Strings(names)
 
-	names := make([]string, numClasses)
-	for ind, group := range ctrlgrp {
-		names[ind] = group.Name()
-	}
+	log.Debug().Msgf("Detected %v classes.", len(names))
 
 	return names, nil
 }
 
-// ReadFile reads in a file.
-// For schemata files, it ensures the file contains just one line, i.e. only
-// one cache level is utilized.
+// ReadFile reads in a file located below the resctrl root.
+// For schemata files, it ensures each cache level is defined once, i.e. by
+// either one line or, with CDP, one line for code and data each. The same
+// applies to the MBA line.
 func (r *Resctrl) ReadFile(path string, isSchemata bool) ([]string, error) {
+	rel, err := r.relPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when trying to open %v. Details: %w", path, err)
+	}
+
 	// open file
-	file, err := os.Open(path)
+	file, err := r.getFS().Open(rel)
 	if err != nil {
 		return nil, fmt.Errorf("error when trying to open %v. Details: %w", path, err)
 	}
@@ -148,12 +592,12 @@ func (r *Resctrl) ReadFile(path string, isSchemata bool) ([]string, error) {
 	scanner.Split(bufio.ScanLines)
 
 	// vars for scanning file
-	catDefined := false
-
 	var (
 		lines       []string
 		keepLine    bool
 		currentLine string
+		catTypes    = map[string]map[string]bool{} // defined CDP types per cache level, "" for unified
+		mbDefined   bool
 	)
 
 	// loop over file rows
@@ -170,26 +614,42 @@ func (r *Resctrl) ReadFile(path string, isSchemata bool) ([]string, error) {
 
 		currentLine = scanner.Text()
 
-		// ensure only one cache level is assigned to buffer
+		// ensure each cache level is defined only once
 		if isSchemata {
-			catReg := regexp.MustCompile(`^\s*L\d:.+`)
+			catReg := regexp.MustCompile(`^\s*(L\d)(CODE|DATA)?:.+`)
 			mbaReg := regexp.MustCompile(`^\s*MB:.+`)
 
+			catMatch := catReg.FindStringSubmatch(currentLine)
+
+			var levelTypes map[string]bool
+			if catMatch != nil {
+				levelTypes = catTypes[catMatch[1]]
+			}
+
 			switch {
-			case catReg.MatchString(currentLine) && !catDefined:
-				catDefined = true
-				keepLine = true
+			case catMatch != nil && (levelTypes[catMatch[2]] || (len(levelTypes) > 0 && (catMatch[2] == "" || levelTypes[""]))):
+				note := "only one definition per cache level or one for code and data each supported per class"
+
+				return nil, fmt.Errorf("%w: several definitions detected in %v: %v", ErrDuplicateCacheLevel, path, note)
 
-			case catReg.MatchString(currentLine) && catDefined:
-				note := "only one cache level definition supported per class"
+			case catMatch != nil:
+				if levelTypes == nil {
+					catTypes[catMatch[1]] = map[string]bool{}
+				}
 
-				return nil, fmt.Errorf("several definitions detected in %v: %v", path, note)
+				catTypes[catMatch[1]][catMatch[2]] = true
+				keepLine = true
+
+			case mbaReg.MatchString(currentLine) && mbDefined:
+				return nil, fmt.Errorf("%w: several definitions detected in %v: only one MBA definition supported",
+					ErrSchemataFormat, path)
 
 			case mbaReg.MatchString(currentLine):
-				keepLine = false
+				mbDefined = true
+				keepLine = true
 
 			default:
-				return nil, fmt.Errorf("unknown format in %v", path)
+				return nil, fmt.Errorf("%w: unknown format in %v", ErrSchemataFormat, path)
 			}
 		} else {
 			keepLine = true
@@ -0,0 +1,546 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing/fstest"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// snapshotRoot is a snapshot of /sys/fs/resctrl with class0 and class1
+// configured for cache level 3 on two cache IDs. snapshotSysRoot is the
+// according snapshot of sysfs with 4 CPUs, each pair sharing one L3 cache.
+// snapshotProcRoot keeps the cgroups of the tasks of the default class.
+const (
+	snapshotRoot     = "testdata/resctrl"
+	snapshotSysRoot  = "testdata/sys"
+	snapshotProcRoot = "testdata/proc"
+)
+
+var _ = Describe("Resctrl", func() {
+	var rdtcatBuffers *rdtcat.Buffers
+
+	Context("When reading a snapshot of /sys/fs/resctrl", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = rdtcat.NewBuffers(snapshotRoot)
+			rdtcatBuffers.SysRoot = snapshotSysRoot
+		})
+
+		It("should detect all classes", func() {
+			Expect(rdtcatBuffers.GetClassNames()).To(Equal([]string{"class0", "class1", rdtcat.DefaultClass}))
+		})
+
+		It("should run the whole discovery pipeline", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.ResctrlGroups).To(HaveLen(3))
+			Expect(rdtcatBuffers.ResctrlGroups[0].Path).To(Equal(path.Join(snapshotRoot, "class0")))
+			Expect(rdtcatBuffers.ResctrlGroups[0].BmSchemata).To(Equal("L3:0=00003;1=00003"))
+			Expect(rdtcatBuffers.ResctrlGroups[0].SizeKib).To(Equal(2560))
+			Expect(rdtcatBuffers.ResctrlGroups[0].CacheIDs).To(Equal([]rdtcat.CacheID{
+				{ID: 0, CacheLevel: "L3", Mask: 0x00003, SizeKib: 2560, SharedCPUs: []int{0, 1}, NumaNodes: []int{0}},
+				{ID: 1, CacheLevel: "L3", Mask: 0x00003, SizeKib: 2560, SharedCPUs: []int{2, 3}, NumaNodes: []int{1}},
+			}))
+			Expect(rdtcatBuffers.ResctrlGroups[0].MbSchemata).To(Equal("MB:0=100;1=100"))
+			Expect(rdtcatBuffers.ResctrlGroups[1].SizeKib).To(Equal(3840))
+			Expect(rdtcatBuffers.ResctrlGroups[1].MbaIDs).To(Equal([]rdtcat.MbaID{
+				{ID: 0, Bandwidth: 50},
+				{ID: 1, Bandwidth: 70},
+			}))
+			Expect(rdtcatBuffers.ResctrlGroups[1].MinBandwidth()).To(Equal(50))
+			Expect(rdtcatBuffers.ResctrlGroups[2].Path).To(Equal(snapshotRoot))
+			Expect(rdtcatBuffers.ResctrlGroups[2].SizeKib).To(Equal(5120))
+
+			Expect(rdtcatBuffers.CreateLabels()).To(Succeed())
+			Expect(rdtcatBuffers.DpL2Label).To(BeEmpty())
+			Expect(rdtcatBuffers.DpL3Label).To(Equal("2560"))
+			Expect(rdtcatBuffers.DpL2Labels.Free).To(BeEmpty())
+			Expect(rdtcatBuffers.DpL3Labels.Free).To(Equal("13"))
+			Expect(rdtcatBuffers.DpL3Labels.Max).To(Equal("3840"))
+			Expect(rdtcatBuffers.DpL3Labels.Mb).To(Equal("50"))
+
+			Expect(rdtcatBuffers.ExtractBuffers(2).ResctrlGroups).To(BeEmpty())
+			Expect(rdtcatBuffers.ExtractBuffers(3).ResctrlGroups).To(HaveLen(3))
+		})
+
+		It("should only keep classes in the given mode", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.ResctrlGroups[0].Mode).To(Equal(rdtcat.ModeExclusive))
+			Expect(rdtcatBuffers.ResctrlGroups[1].Mode).To(Equal(rdtcat.ModeShareable))
+
+			rdtcatBuffers.KeepMode(rdtcat.ModeExclusive)
+			Expect(rdtcatBuffers.ResctrlGroups).To(HaveLen(2))
+			Expect(rdtcatBuffers.ResctrlGroups[0].Name).To(Equal("class0"))
+			Expect(rdtcatBuffers.ResctrlGroups[1].Name).To(Equal(rdtcat.DefaultClass))
+		})
+
+		It("should read the PIDs of a class", func() {
+			Expect(rdtcatBuffers.GetBufferPids(path.Join(snapshotRoot, "tasks"))).To(Equal([]string{"1", "2", "3"}))
+			Expect(rdtcatBuffers.GetBufferPids(path.Join(snapshotRoot, "class0", "tasks"))).To(BeEmpty())
+		})
+
+		It("should refuse to read files outside of the resctrl root", func() {
+			_, err := rdtcatBuffers.ReadFile("/etc/hostname", false)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("When reading from an in-memory filesystem", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":               {Data: []byte("    L2:0=f0;1=f0\n")},
+				"size":                   {Data: []byte("    L2:0=524288;1=524288\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"c0/schemata":            {Data: []byte("    L2:0=0f;1=0f\n")},
+				"c0/size":                {Data: []byte("    L2:0=524288;1=524288\n")},
+				"c0/tasks":               {Data: []byte("")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("2\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+		})
+
+		It("should read all buffers below the given root", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.ResctrlGroups).To(HaveLen(2))
+			Expect(rdtcatBuffers.ResctrlGroups[0].Name).To(Equal("c0"))
+			Expect(rdtcatBuffers.ResctrlGroups[0].Path).To(Equal("/snapshot/c0"))
+			Expect(rdtcatBuffers.ResctrlGroups[0].CacheLevel).To(Equal("L2"))
+			Expect(rdtcatBuffers.ResctrlGroups[0].SizeKib).To(Equal(512))
+			Expect(rdtcatBuffers.Capabilities).To(HaveKey("L2"))
+			Expect(rdtcatBuffers.Capabilities["L2"].BitUsage).To(BeEmpty())
+			Expect(rdtcatBuffers.Capabilities["L2"].BytesPerBit).To(Equal(131072))
+			Expect(rdtcatBuffers.Capabilities["L2"].FreeBuffers).To(Equal(1))
+		})
+	})
+
+	Context("When classes have no size file", func() {
+		var resctrlFS fstest.MapFS
+
+		BeforeEach(func() {
+			resctrlFS = fstest.MapFS{
+				"schemata":               {Data: []byte("L2:0=f0;1=f0\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"c0/schemata":            {Data: []byte("L2:0=0f;1=03\n")},
+				"c0/tasks":               {Data: []byte("")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("2\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+			}
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", resctrlFS)
+			rdtcatBuffers.SysFS = fstest.MapFS{
+				"devices/system/cpu/cpu0/cache/index2/level":           {Data: []byte("2\n")},
+				"devices/system/cpu/cpu0/cache/index2/id":              {Data: []byte("0\n")},
+				"devices/system/cpu/cpu0/cache/index2/size":            {Data: []byte("1024K\n")},
+				"devices/system/cpu/cpu0/cache/index2/shared_cpu_list": {Data: []byte("0\n")},
+				"devices/system/cpu/cpu1/cache/index2/level":           {Data: []byte("2\n")},
+				"devices/system/cpu/cpu1/cache/index2/id":              {Data: []byte("1\n")},
+				"devices/system/cpu/cpu1/cache/index2/size":            {Data: []byte("1024K\n")},
+				"devices/system/cpu/cpu1/cache/index2/shared_cpu_list": {Data: []byte("1\n")},
+			}
+		})
+
+		It("should derive the sizes from the bitmasks and the cache size", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.ResctrlGroups[0].SizeDerived).To(BeTrue())
+			Expect(rdtcatBuffers.ResctrlGroups[0].LevelSizeKib("L2")).To(Equal(256))
+			Expect(rdtcatBuffers.ResctrlGroups[0].LevelMaxSizeKib("L2")).To(Equal(512))
+			Expect(rdtcatBuffers.Capabilities["L2"].BytesPerBit).To(Equal(131072))
+			Expect(rdtcatBuffers.SizeMismatches).To(BeEmpty())
+		})
+
+		It("should report sizes differing from the bitmasks", func() {
+			resctrlFS["size"] = &fstest.MapFile{Data: []byte("L2:0=524288;1=524288\n")}
+			resctrlFS["c0/size"] = &fstest.MapFile{Data: []byte("L2:0=524288;1=524288\n")}
+
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.ResctrlGroups[0].SizeDerived).To(BeFalse())
+			Expect(rdtcatBuffers.ResctrlGroups[0].LevelMaxSizeKib("L2")).To(Equal(512))
+			Expect(rdtcatBuffers.SizeMismatches).To(Equal([]rdtcat.SizeMismatch{{
+				Class: "c0", CacheLevel: "L2", CacheID: 1, SizeKib: 512, DerivedSizeKib: 256,
+			}}))
+		})
+
+		It("should return an error if the cache size is unknown", func() {
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrSchemataFormat))
+		})
+	})
+
+	Context("When cache IDs are configured with different sizes", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":               {Data: []byte("    L2:0=f0;1=f0\n")},
+				"size":                   {Data: []byte("    L2:0=524288;1=524288\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"c0/schemata":            {Data: []byte("    L2:0=03;1=0f\n")},
+				"c0/size":                {Data: []byte("    L2:0=262144;1=524288\n")},
+				"c0/tasks":               {Data: []byte("")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("2\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+		})
+
+		It("should keep the size of each cache ID and advertize the smallest size", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+
+			group := rdtcatBuffers.ResctrlGroups[0]
+			Expect(group.SizeKib).To(Equal(256))
+			Expect(group.MinSizeKib()).To(Equal(256))
+			Expect(group.MaxSizeKib()).To(Equal(512))
+			cacheID, ok := group.GetCacheID("L2", 1)
+			Expect(ok).To(BeTrue())
+			Expect(cacheID).To(Equal(rdtcat.CacheID{ID: 1, CacheLevel: "L2", Mask: 0x0f, SizeKib: 512}))
+
+			Expect(rdtcatBuffers.CreateLabels()).To(Succeed())
+			Expect(rdtcatBuffers.DpL2Label).To(Equal("256"))
+			Expect(rdtcatBuffers.DpL2Labels.Max).To(Equal("512"))
+		})
+	})
+
+	Context("When bitmask and size schemata cover different cache IDs", func() {
+		It("should return an error", func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata": {Data: []byte("    L2:0=f0;1=f0\n")},
+				"size":     {Data: []byte("    L2:0=524288\n")},
+				"tasks":    {Data: []byte("1\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrSchemataFormat))
+		})
+	})
+
+	Context("When resctrl is mounted with Code and Data Prioritization", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":                   {Data: []byte("L3CODE:0=f00;1=f00\nL3DATA:0=f00;1=f00\n")},
+				"size":                       {Data: []byte("L3CODE:0=262144;1=262144\nL3DATA:0=262144;1=262144\n")},
+				"tasks":                      {Data: []byte("1\n")},
+				"c0/schemata":                {Data: []byte("L3CODE:0=00f;1=003\nL3DATA:0=0f0;1=0f0\n")},
+				"c0/size":                    {Data: []byte("L3CODE:0=262144;1=131072\nL3DATA:0=262144;1=262144\n")},
+				"c0/tasks":                   {Data: []byte("")},
+				"info/L3CODE/num_closids":    {Data: []byte("8\n")},
+				"info/L3CODE/cbm_mask":       {Data: []byte("fff\n")},
+				"info/L3CODE/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L3CODE/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+		})
+
+		It("should keep code and data partitions of each cache ID", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.IsCdp()).To(BeTrue())
+
+			group := rdtcatBuffers.ResctrlGroups[0]
+			Expect(group.Cdp).To(BeTrue())
+			Expect(group.CacheLevel).To(Equal("L3"))
+			Expect(group.CacheIDs).To(Equal([]rdtcat.CacheID{
+				{
+					ID: 0, CacheLevel: "L3", Mask: 0x0ff, SizeKib: 512,
+					CodeMask: 0x00f, CodeSizeKib: 256, DataMask: 0x0f0, DataSizeKib: 256,
+				},
+				{
+					ID: 1, CacheLevel: "L3", Mask: 0x0f3, SizeKib: 384,
+					CodeMask: 0x003, CodeSizeKib: 128, DataMask: 0x0f0, DataSizeKib: 256,
+				},
+			}))
+			Expect(group.SizeKib).To(Equal(384))
+			Expect(group.CodeSizeKib()).To(Equal(128))
+			Expect(group.DataSizeKib()).To(Equal(256))
+
+			Expect(rdtcatBuffers.Capabilities["L3"].BytesPerBit).To(Equal(65536))
+			Expect(rdtcatBuffers.Capabilities["L3"].FreeBuffers).To(Equal(3))
+		})
+
+		It("should create size labels for both partitions", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.CreateLabels()).To(Succeed())
+			Expect(rdtcatBuffers.DpL3Label).To(Equal("384"))
+			Expect(rdtcatBuffers.DpL3Labels).To(Equal(rdtcat.LevelLabels{
+				Free: "3", Max: "512", Code: "128", Data: "256",
+			}))
+		})
+	})
+
+	Context("When a CDP definition is incomplete", func() {
+		It("should return an error", func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata": {Data: []byte("L3CODE:0=f00\n")},
+				"size":     {Data: []byte("L3CODE:0=262144\n")},
+				"tasks":    {Data: []byte("1\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrSchemataFormat))
+		})
+	})
+
+	Context("When unified and CDP definitions are mixed", func() {
+		It("should return an error", func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata": {Data: []byte("L3:0=f00\nL3CODE:0=f00\n")},
+				"size":     {Data: []byte("L3:0=262144\nL3CODE:0=262144\n")},
+				"tasks":    {Data: []byte("1\n")},
+			})
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrDuplicateCacheLevel))
+		})
+	})
+
+	Context("When classes allocate both, L2 and L3 cache", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":               {Data: []byte("L2:0=f0;1=f0\nL3:0=f00;1=f00\n")},
+				"size":                   {Data: []byte("L2:0=262144;1=262144\nL3:0=1048576;1=1048576\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"c0/schemata":            {Data: []byte("L2:0=03;1=03\nL3:0=003;1=003\n")},
+				"c0/size":                {Data: []byte("L2:0=131072;1=131072\nL3:0=524288;1=524288\n")},
+				"c0/tasks":               {Data: []byte("")},
+				"c1/schemata":            {Data: []byte("L3:0=0f0;1=0f0\n")},
+				"c1/size":                {Data: []byte("L3:0=1048576;1=1048576\n")},
+				"c1/tasks":               {Data: []byte("")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+				"info/L3/num_closids":    {Data: []byte("8\n")},
+				"info/L3/cbm_mask":       {Data: []byte("fff\n")},
+				"info/L3/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L3/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+		})
+
+		It("should keep the cache IDs of each cache level", func() {
+			group := rdtcatBuffers.ResctrlGroups[0]
+			Expect(group.CacheLevel).To(Equal("L2+L3"))
+			Expect(group.CacheLevels()).To(Equal([]string{"L2", "L3"}))
+			Expect(group.CacheIDs).To(HaveLen(4))
+			Expect(group.LevelSizeKib("L2")).To(Equal(128))
+			Expect(group.LevelSizeKib("L3")).To(Equal(512))
+			cacheID, ok := group.GetCacheID("L3", 1)
+			Expect(ok).To(BeTrue())
+			Expect(cacheID).To(Equal(rdtcat.CacheID{ID: 1, CacheLevel: "L3", Mask: 0x003, SizeKib: 512}))
+
+			Expect(rdtcatBuffers.Capabilities).To(HaveLen(2))
+			Expect(rdtcatBuffers.Capabilities["L2"].FreeBuffers).To(Equal(5))
+			Expect(rdtcatBuffers.Capabilities["L3"].FreeBuffers).To(Equal(5))
+			Expect(rdtcatBuffers.CheckExclusivity().IsExclusive()).To(BeTrue())
+		})
+
+		It("should extract the classes allocating both cache levels separately", func() {
+			Expect(rdtcatBuffers.ExtractBuffers(2).ResctrlGroups).To(BeEmpty())
+			Expect(rdtcatBuffers.ExtractBuffers(3).ResctrlGroups).To(HaveLen(1))
+			Expect(rdtcatBuffers.ExtractBuffers(3).ResctrlGroups[0].Name).To(Equal("c1"))
+			Expect(rdtcatBuffers.ExtractBuffers(2, 3).ResctrlGroups).To(HaveLen(2))
+			Expect(rdtcatBuffers.ExtractBuffers(2, 3).ResctrlGroups[0].Name).To(Equal("c0"))
+		})
+
+		It("should label both sizes of the classes allocating both cache levels", func() {
+			Expect(rdtcatBuffers.CreateLabels()).To(Succeed())
+			Expect(rdtcatBuffers.DpL2Label).To(BeEmpty())
+			Expect(rdtcatBuffers.DpL3Label).To(Equal("1024"))
+			Expect(rdtcatBuffers.DpL2L3Labels).To(Equal(rdtcat.L2L3Labels{L2: "128", L3: "512"}))
+		})
+	})
+
+	Context("When a cache level is defined twice", func() {
+		It("should return an error", func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata": {Data: []byte("L3:0=f00\nL3:0=0f0\n")},
+				"size":     {Data: []byte("L3:0=1048576\nL3:0=1048576\n")},
+				"tasks":    {Data: []byte("1\n")},
+			})
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrDuplicateCacheLevel))
+		})
+	})
+
+	Context("When MBA is defined twice", func() {
+		It("should return an error", func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata": {Data: []byte("L3:0=f00\nMB:0=100\nMB:0=50\n")},
+				"size":     {Data: []byte("L3:0=1048576\nMB:0=100\nMB:0=50\n")},
+				"tasks":    {Data: []byte("1\n")},
+			})
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrSchemataFormat))
+		})
+	})
+
+	Context("When only the default class is configured", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":               {Data: []byte("L2:0=ff\n")},
+				"size":                   {Data: []byte("L2:0=524288\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+		})
+
+		It("should report that no classes are configured", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrNoClasses))
+		})
+
+		It("should read in the default class nevertheless", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrNoClasses))
+			Expect(rdtcatBuffers.ResctrlGroups).To(HaveLen(1))
+			Expect(rdtcatBuffers.ResctrlGroups[0].Name).To(Equal(rdtcat.DefaultClass))
+			Expect(rdtcatBuffers.Capabilities).To(HaveKey("L2"))
+		})
+
+		It("should not create any labels", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrNoClasses))
+			Expect(rdtcatBuffers.CreateLabels()).To(MatchError(rdtcat.ErrNoClasses))
+		})
+	})
+
+	Context("When a class is pseudo-locked", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":               {Data: []byte("L2:0=f0;1=f0\n")},
+				"size":                   {Data: []byte("L2:0=262144;1=262144\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"c0/schemata":            {Data: []byte("L2:0=03;1=03\n")},
+				"c0/size":                {Data: []byte("L2:0=131072;1=131072\n")},
+				"c0/tasks":               {Data: []byte("")},
+				"c0/mode":                {Data: []byte("exclusive\n")},
+				"locked/schemata":        {Data: []byte("L2:1=3c\n")},
+				"locked/size":            {Data: []byte("L2:1=262144\n")},
+				"locked/tasks":           {Data: []byte("")},
+				"locked/mode":            {Data: []byte("pseudo-locked\n")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+		})
+
+		It("should report the locked region", func() {
+			Expect(rdtcatBuffers.ResctrlGroups[1].IsPseudoLocked()).To(BeTrue())
+			Expect(rdtcatBuffers.LockedRegions()).To(Equal([]rdtcat.LockedRegion{{
+				Class: "locked", CacheLevel: "L2", CacheID: 1, Mask: 0x3c, SizeKib: 256,
+				Device: "/dev/pseudo_lock/locked",
+			}}))
+		})
+
+		It("should label the locked region separately", func() {
+			Expect(rdtcatBuffers.CreateLabels()).To(Succeed())
+			Expect(rdtcatBuffers.DpL2Label).To(Equal("128"))
+			Expect(rdtcatBuffers.DpL2Labels.Max).To(Equal("128"))
+			Expect(rdtcatBuffers.DpL2Labels.Locked).To(Equal("256"))
+		})
+
+		It("should extract the pseudo-locked buffers separately", func() {
+			Expect(rdtcatBuffers.ExtractBuffers(2).ResctrlGroups).To(HaveLen(2))
+			Expect(rdtcatBuffers.ExtractBuffers(2).ResctrlGroups[0].Name).To(Equal("c0"))
+			Expect(rdtcatBuffers.ExtractLockedBuffers(2).ResctrlGroups).To(HaveLen(1))
+			Expect(rdtcatBuffers.ExtractLockedBuffers(2).ResctrlGroups[0].Name).To(Equal("locked"))
+		})
+	})
+
+	Context("When a class is about to be pseudo-locked", func() {
+		BeforeEach(func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":               {Data: []byte("L2:0=f0;1=f0\n")},
+				"size":                   {Data: []byte("L2:0=262144;1=262144\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"c0/schemata":            {Data: []byte("L2:0=03;1=03\n")},
+				"c0/size":                {Data: []byte("L2:0=131072;1=131072\n")},
+				"c0/tasks":               {Data: []byte("")},
+				"c0/mode":                {Data: []byte("exclusive\n")},
+				"setup/schemata":         {Data: []byte("L2:uninitialized\n")},
+				"setup/size":             {Data: []byte("L2:uninitialized\n")},
+				"setup/tasks":            {Data: []byte("")},
+				"setup/mode":             {Data: []byte("pseudo-locksetup\n")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+		})
+
+		It("should skip the uninitialized schemata of the class", func() {
+			var setup rdtcat.ResctrlGroup
+
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.ResctrlGroups).To(HaveLen(3))
+
+			Expect(rdtcatBuffers.ResctrlGroups).To(ContainElement(HaveField("Name", "setup"), &setup))
+			Expect(setup.Mode).To(Equal(rdtcat.ModePseudoLockSetup))
+			Expect(setup.IsPseudoLocked()).To(BeTrue())
+			Expect(setup.CacheIDs).To(BeEmpty())
+		})
+
+		It("should advertise neither a buffer nor a locked region", func() {
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+			Expect(rdtcatBuffers.CreateLabels()).To(Succeed())
+
+			Expect(rdtcatBuffers.ExtractBuffers(2).ResctrlGroups).To(HaveLen(2))
+			Expect(rdtcatBuffers.ExtractLockedBuffers(2).ResctrlGroups).To(BeEmpty())
+			Expect(rdtcatBuffers.LockedRegions()).To(BeEmpty())
+			Expect(rdtcatBuffers.DpL2Label).To(Equal("128"))
+			Expect(rdtcatBuffers.DpL2Labels.Locked).To(BeEmpty())
+		})
+	})
+
+	Context("When setting the mode of a class", func() {
+		var root string
+
+		BeforeEach(func() {
+			root = GinkgoT().TempDir()
+			Expect(os.Mkdir(filepath.Join(root, "c0"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(root, "c0", "mode"), []byte("shareable\n"), 0o644)).To(Succeed())
+		})
+
+		It("should write the mode file of the class", func() {
+			resctrl := rdtcat.NewResctrl(root)
+			Expect(resctrl.SetMode("c0", rdtcat.ModeExclusive)).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(root, "c0", "mode"))).To(Equal([]byte("exclusive\n")))
+		})
+
+		It("should refuse to change the mode of the default class", func() {
+			resctrl := rdtcat.NewResctrl(root)
+			Expect(resctrl.SetMode(rdtcat.DefaultClass, rdtcat.ModeExclusive)).NotTo(Succeed())
+		})
+
+		It("should refuse to write a snapshot", func() {
+			resctrl := rdtcat.NewResctrlFS(root, fstest.MapFS{"c0/mode": {Data: []byte("shareable\n")}})
+			Expect(resctrl.SetMode("c0", rdtcat.ModeExclusive)).To(MatchError(rdtcat.ErrReadOnly))
+			Expect(os.ReadFile(filepath.Join(root, "c0", "mode"))).To(Equal([]byte("shareable\n")))
+		})
+
+		It("should not create a missing mode file", func() {
+			Expect(os.Mkdir(filepath.Join(root, "c1"), 0o755)).To(Succeed())
+
+			resctrl := rdtcat.NewResctrl(root)
+			Expect(resctrl.SetMode("c1", rdtcat.ModeExclusive)).To(MatchError(fs.ErrNotExist))
+			Expect(filepath.Join(root, "c1", "mode")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("When no resctrl filesystem is available", func() {
+		It("should return an error", func() {
+			rdtcatBuffers = rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{})
+			Expect(rdtcatBuffers.GetAllBuffers()).To(MatchError(rdtcat.ErrRDTUnsupported))
+		})
+	})
+})
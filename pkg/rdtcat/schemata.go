@@ -0,0 +1,72 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cacheIDValue keeps the value configured for one cache ID in a schemata line.
+type cacheIDValue struct {
+	id    int
+	value string
+}
+
+// parseSchemata splits a schemata line like "L3:0=00003;1=00003" into the
+// resource name and the values configured for each cache ID.
+func parseSchemata(line string) (string, []cacheIDValue, error) {
+	const schemataParts = 2
+
+	split := strings.Split(line, ":")
+	if len(split) != schemataParts || split[0] == "" {
+		return "", nil, fmt.Errorf("%w: line %q", ErrSchemataFormat, line)
+	}
+
+	var values []cacheIDValue
+
+	for _, entry := range strings.Split(split[1], ";") {
+		idSplit := strings.Split(entry, "=")
+		if len(idSplit) != schemataParts || idSplit[1] == "" {
+			return "", nil, fmt.Errorf("%w: line %q", ErrSchemataFormat, line)
+		}
+
+		id, err := strconv.Atoi(idSplit[0])
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: invalid cache ID in line %q: %w", ErrSchemataFormat, line, err)
+		}
+
+		values = append(values, cacheIDValue{id: id, value: idSplit[1]})
+	}
+
+	return split[0], values, nil
+}
+
+// splitCatResource splits the resource of a schemata line like "L3CODE" into
+// the cache level and the CDP type, which is empty for unified caches.
+func splitCatResource(resource string) (string, string) {
+	for _, cdpType := range []string{CdpCode, CdpData} {
+		if strings.HasSuffix(resource, cdpType) {
+			return strings.TrimSuffix(resource, cdpType), cdpType
+		}
+	}
+
+	return resource, ""
+}
+
+// parseBitmask parses a capacity bitmask given as hex string.
+func parseBitmask(s string) (uint64, error) {
+	const (
+		base    = 16
+		bitSize = 64
+	)
+
+	mask, err := strconv.ParseUint(s, base, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bitmask %q: %w", s, err)
+	}
+
+	return mask, nil
+}
@@ -0,0 +1,150 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/bits"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SizeMismatch keeps a difference between the size reported in the size file
+// and the size derived from the bitmask and the cache geometry of one cache ID.
+type SizeMismatch struct {
+	Class          string
+	CacheLevel     string
+	CacheID        int
+	CdpType        string // CODE or DATA partition, empty without CDP
+	SizeKib        int    // size reported by the kernel
+	DerivedSizeKib int    // size derived from the bitmask
+}
+
+// String returns a human readable description of the mismatch.
+func (m SizeMismatch) String() string {
+	return fmt.Sprintf("class %v reports %v KiB on %v%v cache ID %v, but its bitmask covers %v KiB",
+		m.Class, m.SizeKib, m.CacheLevel, m.CdpType, m.CacheID, m.DerivedSizeKib)
+}
+
+// partition refers to the bitmask and size of one partition of a cache ID.
+type partition struct {
+	cdpType string
+	mask    uint64
+	sizeKib *int
+}
+
+// checkSizes derives the size of each cache ID of the group from its bitmask,
+// the width of the cache level's cbm_mask and the cache size reported in
+// sysfs, similar to how the kernel computes the size file. Groups without size
+// file get the derived sizes, for all other groups mismatches are logged and
+// kept in SizeMismatches. The bit widths are cached in cbmBits, keyed by info
+// directory.
+func (r *Buffers) checkSizes(group *ResctrlGroup, cbmBits map[string]int) error {
+	for ind := range group.CacheIDs {
+		cacheID := &group.CacheIDs[ind]
+
+		bytesPerBit, err := r.bytesPerBit(cacheID, cbmBits)
+		if err != nil {
+			return err
+		}
+
+		if bytesPerBit == 0 {
+			if group.SizeDerived {
+				return fmt.Errorf("%w: no size file in %v and size of %v cache ID %v unknown",
+					ErrSchemataFormat, group.Path, cacheID.CacheLevel, cacheID.ID)
+			}
+
+			continue
+		}
+
+		partitions := []partition{{mask: cacheID.Mask, sizeKib: &cacheID.SizeKib}}
+		if cacheID.CodeMask != 0 {
+			partitions = []partition{
+				{cdpType: CdpCode, mask: cacheID.CodeMask, sizeKib: &cacheID.CodeSizeKib},
+				{cdpType: CdpData, mask: cacheID.DataMask, sizeKib: &cacheID.DataSizeKib},
+			}
+		}
+
+		for _, part := range partitions {
+			derived := bytes2kib(bytesPerBit * bits.OnesCount64(part.mask))
+
+			if group.SizeDerived {
+				*part.sizeKib = derived
+
+				continue
+			}
+
+			if *part.sizeKib != derived {
+				mismatch := SizeMismatch{
+					Class:          group.Name,
+					CacheLevel:     cacheID.CacheLevel,
+					CacheID:        cacheID.ID,
+					CdpType:        part.cdpType,
+					SizeKib:        *part.sizeKib,
+					DerivedSizeKib: derived,
+				}
+
+				log.Warn().Msgf("Size mismatch: %v", mismatch)
+
+				r.SizeMismatches = append(r.SizeMismatches, mismatch)
+			}
+		}
+
+		if group.SizeDerived && cacheID.CodeMask != 0 {
+			cacheID.SizeKib = cacheID.CodeSizeKib + cacheID.DataSizeKib
+		}
+	}
+
+	if group.SizeDerived {
+		group.SizeKib = group.MinSizeKib()
+	}
+
+	return nil
+}
+
+// bytesPerBit returns the cache size allocated by one bit of the bitmask on
+// the given cache ID, 0 if the cache geometry is unknown.
+func (r *Buffers) bytesPerBit(cacheID *CacheID, cbmBits map[string]int) (int, error) {
+	if r.Topology == nil {
+		return 0, nil
+	}
+
+	cache, ok := r.Topology.GetCache(cacheID.CacheLevel, cacheID.ID)
+	if !ok || cache.SizeKib == 0 {
+		return 0, nil
+	}
+
+	// with CDP, the info of the code and data partition is kept in separate
+	// directories providing the same capabilities
+	infoDir := cacheID.CacheLevel
+	if cacheID.CodeMask != 0 {
+		infoDir += CdpCode
+	}
+
+	width, ok := cbmBits[infoDir]
+	if !ok {
+		value, err := r.readInfoFile(infoDir, "cbm_mask")
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		mask, err := parseBitmask(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cbm_mask for %v: %w", infoDir, err)
+		}
+
+		width = bits.Len64(mask)
+		cbmBits[infoDir] = width
+	}
+
+	if width == 0 {
+		return 0, nil
+	}
+
+	return kib2bytes(cache.SizeKib) / width, nil
+}
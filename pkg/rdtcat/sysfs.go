@@ -0,0 +1,75 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SysfsPath is the default mount point of sysfs.
+const SysfsPath = "/sys"
+
+// GetSysRoot returns the sysfs root directory.
+func (r *Resctrl) GetSysRoot() string {
+	if r.SysRoot == "" {
+		return SysfsPath
+	}
+
+	return r.SysRoot
+}
+
+// getSysFS returns the filesystem rooted at the sysfs root directory.
+func (r *Resctrl) getSysFS() fs.FS {
+	if r.SysFS == nil {
+		r.SysFS = os.DirFS(r.GetSysRoot())
+	}
+
+	return r.SysFS
+}
+
+// readSysfsFile reads a single value sysfs file.
+func readSysfsFile(fsys fs.FS, name string) (string, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("error when reading %v: %w", name, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// parseCPUList parses a CPU list like "0-3,8,10-11" as used by sysfs and
+// resctrl.
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+
+	if list == "" {
+		return cpus, nil
+	}
+
+	for _, cpuRange := range strings.Split(list, ",") {
+		bounds := strings.SplitN(cpuRange, "-", 2) //nolint:gomnd // lower and upper bound
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q: %w", list, err)
+		}
+
+		last := first
+		if len(bounds) > 1 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid CPU list %q: %w", list, err)
+			}
+		}
+
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
@@ -0,0 +1,263 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// cache types reported in sysfs
+const (
+	CacheTypeUnified     = "Unified"
+	CacheTypeData        = "Data"
+	CacheTypeInstruction = "Instruction"
+)
+
+// Cache keeps one cache of the CPU cache hierarchy as reported in
+// /sys/devices/system/cpu/cpu*/cache/index*.
+type Cache struct {
+	CacheLevel string // e.g. "L3"
+	ID         int
+	Type       string
+	SizeKib    int
+	Ways       int   // ways of associativity, 0 if unknown
+	SharedCPUs []int // CPUs sharing the cache
+	NumaNodes  []int // NUMA nodes of the CPUs sharing the cache, empty if unknown
+}
+
+// Topology keeps the caches and NUMA nodes of the platform. Only unified
+// caches are kept since RDT allocates unified caches only.
+type Topology struct {
+	Caches   []Cache     // sorted by cache level and ID
+	CPUNodes map[int]int // NUMA node of each CPU
+}
+
+// GetCache returns the cache of the given cache level and ID.
+func (t *Topology) GetCache(cacheLevel string, id int) (Cache, bool) {
+	for _, cache := range t.Caches {
+		if cache.CacheLevel == cacheLevel && cache.ID == id {
+			return cache, true
+		}
+	}
+
+	return Cache{}, false
+}
+
+// CPUs returns the CPUs sharing the cache of the given cache level and ID,
+// nil if unknown.
+func (t *Topology) CPUs(cacheLevel string, id int) []int {
+	cache, _ := t.GetCache(cacheLevel, id)
+
+	return cache.SharedCPUs
+}
+
+// NumaNodes returns the NUMA nodes of the cache of the given cache level and
+// ID, nil if unknown.
+func (t *Topology) NumaNodes(cacheLevel string, id int) []int {
+	cache, _ := t.GetCache(cacheLevel, id)
+
+	return cache.NumaNodes
+}
+
+// ReadTopology reads the CPU cache hierarchy and the NUMA nodes from sysfs.
+// Caches without id file (e.g. on older kernels) are skipped. Without NUMA
+// information, the NUMA nodes of all caches are empty.
+func (r *Resctrl) ReadTopology() (*Topology, error) {
+	cpuNodes, err := r.readCPUNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	caches, err := r.readCaches()
+	if err != nil {
+		return nil, err
+	}
+
+	topology := &Topology{CPUNodes: cpuNodes}
+
+	for _, cache := range caches {
+		cache.NumaNodes = numaNodes(cache.SharedCPUs, cpuNodes)
+		topology.Caches = append(topology.Caches, cache)
+	}
+
+	sort.Slice(topology.Caches, func(i, j int) bool {
+		if topology.Caches[i].CacheLevel != topology.Caches[j].CacheLevel {
+			return topology.Caches[i].CacheLevel < topology.Caches[j].CacheLevel
+		}
+
+		return topology.Caches[i].ID < topology.Caches[j].ID
+	})
+
+	if len(topology.Caches) == 0 {
+		log.Debug().Msgf("No CPU cache info found in %v.", r.GetSysRoot())
+	}
+
+	return topology, nil
+}
+
+// cacheKey identifies a cache of the CPU cache hierarchy.
+type cacheKey struct {
+	cacheLevel string
+	id         int
+}
+
+// readCaches reads all unified caches of the CPU cache hierarchy. All CPUs
+// sharing a cache report the same cache, it is read only once.
+func (r *Resctrl) readCaches() (map[cacheKey]Cache, error) {
+	fsys := r.getSysFS()
+
+	dirs, err := fs.Glob(fsys, "devices/system/cpu/cpu[0-9]*/cache/index[0-9]*")
+	if err != nil {
+		return nil, fmt.Errorf("error when reading CPU caches in %v: %w", r.GetSysRoot(), err)
+	}
+
+	caches := map[cacheKey]Cache{}
+
+	for _, dir := range dirs {
+		level, err := readSysfsFile(fsys, path.Join(dir, "level"))
+		if err != nil {
+			continue
+		}
+
+		idStr, err := readSysfsFile(fsys, path.Join(dir, "id"))
+		if err != nil {
+			continue
+		}
+
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache ID in %v: %w", dir, err)
+		}
+
+		// older kernels don't report the type, assume a unified cache then
+		cacheType, err := readSysfsFile(fsys, path.Join(dir, "type"))
+		if err != nil {
+			cacheType = CacheTypeUnified
+		}
+
+		key := cacheKey{cacheLevel: "L" + level, id: id}
+		if _, ok := caches[key]; ok || cacheType != CacheTypeUnified {
+			continue
+		}
+
+		cache, err := readCache(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		cache.CacheLevel, cache.ID, cache.Type = key.cacheLevel, id, cacheType
+		caches[key] = cache
+	}
+
+	return caches, nil
+}
+
+// readCache reads the sharing CPUs, size and ways of a cache. Size and ways
+// are 0 if not reported.
+func readCache(fsys fs.FS, dir string) (Cache, error) {
+	var cache Cache
+
+	cpuList, err := readSysfsFile(fsys, path.Join(dir, "shared_cpu_list"))
+	if err != nil {
+		return cache, err
+	}
+
+	if cache.SharedCPUs, err = parseCPUList(cpuList); err != nil {
+		return cache, fmt.Errorf("error in %v: %w", dir, err)
+	}
+
+	if size, err := readSysfsFile(fsys, path.Join(dir, "size")); err == nil {
+		if cache.SizeKib, err = parseCacheSize(size); err != nil {
+			return cache, fmt.Errorf("error in %v: %w", dir, err)
+		}
+	}
+
+	if ways, err := readSysfsFile(fsys, path.Join(dir, "ways_of_associativity")); err == nil {
+		if cache.Ways, err = strconv.Atoi(ways); err != nil {
+			return cache, fmt.Errorf("invalid ways of associativity in %v: %w", dir, err)
+		}
+	}
+
+	return cache, nil
+}
+
+// parseCacheSize parses a cache size like "25600K" as used by sysfs and
+// returns it in KiB.
+func parseCacheSize(size string) (int, error) {
+	unit := 1
+
+	switch {
+	case strings.HasSuffix(size, "K"):
+		size = strings.TrimSuffix(size, "K")
+	case strings.HasSuffix(size, "M"):
+		size, unit = strings.TrimSuffix(size, "M"), 1024 //nolint:gomnd // KiB per MiB
+	}
+
+	value, err := strconv.Atoi(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache size %q: %w", size, err)
+	}
+
+	return value * unit, nil
+}
+
+// readCPUNodes reads the NUMA node of each CPU from
+// /sys/devices/system/node. The map is empty if no NUMA information is
+// available.
+func (r *Resctrl) readCPUNodes() (map[int]int, error) {
+	fsys := r.getSysFS()
+
+	dirs, err := fs.Glob(fsys, "devices/system/node/node[0-9]*")
+	if err != nil {
+		return nil, fmt.Errorf("error when reading NUMA nodes in %v: %w", r.GetSysRoot(), err)
+	}
+
+	cpuNodes := map[int]int{}
+
+	for _, dir := range dirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(path.Base(dir), "node"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid NUMA node %v: %w", dir, err)
+		}
+
+		cpuList, err := readSysfsFile(fsys, path.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+
+		cpus, err := parseCPUList(cpuList)
+		if err != nil {
+			return nil, fmt.Errorf("error in %v: %w", dir, err)
+		}
+
+		for _, cpu := range cpus {
+			cpuNodes[cpu] = node
+		}
+	}
+
+	return cpuNodes, nil
+}
+
+// numaNodes returns the sorted NUMA nodes of the given CPUs.
+func numaNodes(cpus []int, cpuNodes map[int]int) []int {
+	var nodes []int
+
+	for _, cpu := range cpus {
+		if node, ok := cpuNodes[cpu]; ok && !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	sort.Ints(nodes)
+
+	return nodes
+}
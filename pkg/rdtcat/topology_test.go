@@ -0,0 +1,86 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"testing/fstest"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Topology", func() {
+	Context("When reading a snapshot of /sys", func() {
+		It("should read the caches and NUMA nodes", func() {
+			resctrl := rdtcat.NewResctrl(snapshotRoot)
+			resctrl.SysRoot = snapshotSysRoot
+
+			topology, err := resctrl.ReadTopology()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(topology.Caches).To(HaveLen(6))
+			Expect(topology.CPUNodes).To(Equal(map[int]int{0: 0, 1: 0, 2: 1, 3: 1}))
+
+			cache, ok := topology.GetCache("L3", 1)
+			Expect(ok).To(BeTrue())
+			Expect(cache).To(Equal(rdtcat.Cache{
+				CacheLevel: "L3", ID: 1, Type: rdtcat.CacheTypeUnified, SizeKib: 25600, Ways: 20,
+				SharedCPUs: []int{2, 3}, NumaNodes: []int{1},
+			}))
+			Expect(topology.CPUs("L2", 2)).To(Equal([]int{2}))
+			Expect(topology.NumaNodes("L2", 2)).To(Equal([]int{1}))
+		})
+
+		It("should join the topology with the cache IDs of each class", func() {
+			rdtcatBuffers := rdtcat.NewBuffers(snapshotRoot)
+			rdtcatBuffers.SysRoot = snapshotSysRoot
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+
+			Expect(rdtcatBuffers.Topology).NotTo(BeNil())
+			cacheID, ok := rdtcatBuffers.ResctrlGroups[0].GetCacheID("L3", 1)
+			Expect(ok).To(BeTrue())
+			Expect(cacheID.SharedCPUs).To(Equal([]int{2, 3}))
+			Expect(cacheID.NumaNodes).To(Equal([]int{1}))
+		})
+	})
+
+	Context("When sysfs reports split and sub-NUMA caches", func() {
+		It("should keep unified caches and all NUMA nodes sharing them", func() {
+			resctrl := rdtcat.NewResctrl("/snapshot")
+			resctrl.SysFS = fstest.MapFS{
+				"devices/system/cpu/cpu0/cache/index0/level":           {Data: []byte("1\n")},
+				"devices/system/cpu/cpu0/cache/index0/id":              {Data: []byte("0\n")},
+				"devices/system/cpu/cpu0/cache/index0/type":            {Data: []byte("Data\n")},
+				"devices/system/cpu/cpu0/cache/index0/shared_cpu_list": {Data: []byte("0\n")},
+				"devices/system/cpu/cpu0/cache/index3/level":           {Data: []byte("3\n")},
+				"devices/system/cpu/cpu0/cache/index3/id":              {Data: []byte("0\n")},
+				"devices/system/cpu/cpu0/cache/index3/type":            {Data: []byte("Unified\n")},
+				"devices/system/cpu/cpu0/cache/index3/size":            {Data: []byte("36M\n")},
+				"devices/system/cpu/cpu0/cache/index3/shared_cpu_list": {Data: []byte("0-1\n")},
+				"devices/system/node/node0/cpulist":                    {Data: []byte("0\n")},
+				"devices/system/node/node1/cpulist":                    {Data: []byte("1\n")},
+			}
+
+			topology, err := resctrl.ReadTopology()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(topology.Caches).To(Equal([]rdtcat.Cache{{
+				CacheLevel: "L3", ID: 0, Type: rdtcat.CacheTypeUnified, SizeKib: 36864,
+				SharedCPUs: []int{0, 1}, NumaNodes: []int{0, 1},
+			}}))
+		})
+
+		It("should return an error for malformed sizes", func() {
+			resctrl := rdtcat.NewResctrl("/snapshot")
+			resctrl.SysFS = fstest.MapFS{
+				"devices/system/cpu/cpu0/cache/index3/level":           {Data: []byte("3\n")},
+				"devices/system/cpu/cpu0/cache/index3/id":              {Data: []byte("0\n")},
+				"devices/system/cpu/cpu0/cache/index3/size":            {Data: []byte("big\n")},
+				"devices/system/cpu/cpu0/cache/index3/shared_cpu_list": {Data: []byte("0-1\n")},
+			}
+
+			_, err := resctrl.ReadTopology()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,117 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FindingKind describes which check a finding of the validation refers to.
+type FindingKind string
+
+const (
+	FindingOverlap      FindingKind = "overlap"       // bitmask shared with another class or shareable bits
+	FindingMixedLevels  FindingKind = "mixed-levels"  // class allocates several cache levels
+	FindingCdp          FindingKind = "cdp"           // code and data partitions differ
+	FindingSizes        FindingKind = "sizes"         // sizes differ across cache IDs
+	FindingSizeMismatch FindingKind = "size-mismatch" // size file differs from the bitmask
+)
+
+// Severities of a finding
+const (
+	SeverityError   = "error"   // ExCAT can't guarantee exclusive buffers
+	SeverityWarning = "warning" // buffers are advertised, but may not behave as expected
+)
+
+// Finding keeps one result of the validation.
+type Finding struct {
+	Kind     FindingKind `json:"kind"`
+	Severity string      `json:"severity"`
+	Class    string      `json:"class"`
+	Message  string      `json:"message"`
+}
+
+// String returns a human readable description of the finding.
+func (f Finding) String() string {
+	return fmt.Sprintf("%v: %v", f.Severity, f.Message)
+}
+
+// Validate checks the buffers for overlapping bitmasks, classes allocating
+// several cache levels, code and data partitions of different sizes, sizes
+// that differ across cache IDs and size files that don't match the bitmasks.
+// Overlaps are errors, all other findings are warnings. Buffers and
+// capabilities have to be read beforehand.
+func (r *Buffers) Validate() []Finding {
+	var findings []Finding
+
+	for _, overlap := range r.CheckExclusivity().Overlaps {
+		findings = append(findings, Finding{
+			Kind: FindingOverlap, Severity: SeverityError, Class: overlap.Class, Message: overlap.String(),
+		})
+	}
+
+	for _, group := range r.ResctrlGroups {
+		if group.Name == DefaultClass {
+			continue
+		}
+
+		findings = append(findings, validateGroup(group)...)
+	}
+
+	for _, mismatch := range r.SizeMismatches {
+		findings = append(findings, Finding{
+			Kind: FindingSizeMismatch, Severity: SeverityWarning, Class: mismatch.Class, Message: mismatch.String(),
+		})
+	}
+
+	return findings
+}
+
+// validateGroup returns the findings of a single class.
+func validateGroup(group ResctrlGroup) []Finding {
+	var findings []Finding
+
+	cacheLevels := group.CacheLevels()
+	if len(cacheLevels) > 1 {
+		findings = append(findings, Finding{
+			Kind: FindingMixedLevels, Severity: SeverityWarning, Class: group.Name,
+			Message: fmt.Sprintf("class %v allocates %v cache and is only advertised as combined resource",
+				group.Name, strings.Join(cacheLevels, " and ")),
+		})
+	}
+
+	for _, cacheLevel := range cacheLevels {
+		if minSize, maxSize := group.LevelSizeKib(cacheLevel), group.LevelMaxSizeKib(cacheLevel); minSize != maxSize {
+			findings = append(findings, Finding{
+				Kind: FindingSizes, Severity: SeverityWarning, Class: group.Name,
+				Message: fmt.Sprintf("class %v allocates between %v and %v KiB on the %v cache IDs, %v KiB are advertised",
+					group.Name, minSize, maxSize, cacheLevel, minSize),
+			})
+		}
+	}
+
+	for _, cacheID := range group.CacheIDs {
+		if cacheID.CodeMask != 0 && cacheID.CodeSizeKib != cacheID.DataSizeKib {
+			findings = append(findings, Finding{
+				Kind: FindingCdp, Severity: SeverityWarning, Class: group.Name,
+				Message: fmt.Sprintf("class %v allocates %v KiB for code and %v KiB for data on %v cache ID %v",
+					group.Name, cacheID.CodeSizeKib, cacheID.DataSizeKib, cacheID.CacheLevel, cacheID.ID),
+			})
+		}
+	}
+
+	return findings
+}
+
+// HasErrors returns true if any finding is an error.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
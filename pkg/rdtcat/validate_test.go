@@ -0,0 +1,59 @@
+// Copyright (C) 2023 Intel Corporation
+// SPDX-License-Identifier: Apache-2.0
+
+package rdtcat_test
+
+import (
+	"testing/fstest"
+
+	"github.com/csl-svc/excat/pkg/rdtcat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	Context("When validating a consistent snapshot", func() {
+		It("should not report any errors", func() {
+			rdtcatBuffers := rdtcat.NewBuffers(snapshotRoot)
+			rdtcatBuffers.SysRoot = snapshotSysRoot
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+
+			findings := rdtcatBuffers.Validate()
+			Expect(rdtcat.HasErrors(findings)).To(BeFalse(), "%v", findings)
+		})
+	})
+
+	Context("When buffers overlap and differ across cache IDs", func() {
+		It("should report errors and warnings per class", func() {
+			rdtcatBuffers := rdtcat.NewBuffersFS("/snapshot", fstest.MapFS{
+				"schemata":               {Data: []byte("L3:0=ff0;1=ff0\n")},
+				"size":                   {Data: []byte("L3:0=10485760;1=10485760\n")},
+				"tasks":                  {Data: []byte("1\n")},
+				"c0/schemata":            {Data: []byte("L3:0=f;1=3\n")},
+				"c0/size":                {Data: []byte("L3:0=5242880;1=2621440\n")},
+				"c0/tasks":               {Data: []byte("")},
+				"c1/schemata":            {Data: []byte("L2:0=3\nL3:0=30;1=30\n")},
+				"c1/size":                {Data: []byte("L2:0=262144\nL3:0=2621440;1=2621440\n")},
+				"c1/tasks":               {Data: []byte("")},
+				"info/L2/num_closids":    {Data: []byte("8\n")},
+				"info/L2/cbm_mask":       {Data: []byte("ff\n")},
+				"info/L2/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L2/shareable_bits": {Data: []byte("0\n")},
+				"info/L3/num_closids":    {Data: []byte("16\n")},
+				"info/L3/cbm_mask":       {Data: []byte("fff\n")},
+				"info/L3/min_cbm_bits":   {Data: []byte("1\n")},
+				"info/L3/shareable_bits": {Data: []byte("0\n")},
+			})
+			rdtcatBuffers.SysFS = fstest.MapFS{}
+			Expect(rdtcatBuffers.GetAllBuffers()).To(Succeed())
+
+			findings := rdtcatBuffers.Validate()
+			Expect(rdtcat.HasErrors(findings)).To(BeTrue())
+			Expect(findings).To(ContainElements(
+				HaveField("Kind", rdtcat.FindingOverlap),
+				And(HaveField("Kind", rdtcat.FindingSizes), HaveField("Class", "c0")),
+				And(HaveField("Kind", rdtcat.FindingMixedLevels), HaveField("Class", "c1")),
+			))
+		})
+	})
+})